@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/skycoin/skywire/pkg/util/pathutil"
+
+	"github.com/skycoin/skywire-updater/pkg/store"
+	"github.com/skycoin/skywire-updater/pkg/update"
+)
+
+var migrateFrom string
+
+var migrateDBCmd = &cobra.Command{
+	Use:   "migrate-db [config-path]",
+	Short: "imports a JSON db file into the configured db",
+	Long: `
+migrate-db imports the last updates and update history of a JSON db file into
+the db configured under 'paths' (which should not already exist). It is meant to
+be ran once, after changing 'paths.db-type' from "json".`,
+	Run: func(_ *cobra.Command, args []string) {
+		configPath := pathutil.FindConfigPath(args, 0, configEnv, defaultPaths)
+
+		log.Infof("config path: '%s'", configPath)
+		conf := update.NewConfig(".", "./bin")
+		if err := conf.Parse(configPath); err != nil {
+			log.WithError(err).Fatalln("failed to load config")
+		}
+		if conf.Paths.DBType == store.JSONType {
+			log.Fatalln("'paths.db-type' is already \"json\"; nothing to migrate")
+		}
+		if migrateFrom == "" {
+			migrateFrom = filepath.Join(filepath.Dir(conf.Paths.DBFile), "db.json")
+		}
+		if _, err := os.Stat(conf.Paths.DBFile); err == nil {
+			log.Fatalf("db file %s already exists, stopping to avoid importing twice", conf.Paths.DBFile)
+		}
+
+		db, err := store.New(conf.Paths.DBType, conf.Paths.DBFile)
+		if err != nil {
+			log.WithError(err).Fatalln("failed to load db")
+		}
+		n, err := store.ImportJSON(db, migrateFrom)
+		if cErr := db.Close(); cErr != nil {
+			log.WithError(cErr).Error("failed to close db")
+		}
+		if err != nil {
+			if rmErr := os.Remove(conf.Paths.DBFile); rmErr != nil {
+				log.WithError(rmErr).Error("failed to remove partially imported db")
+			}
+			log.WithError(err).Fatalf("failed to import %s", migrateFrom)
+		}
+		log.Infof("Imported %d service(s) from %s into %s", n, migrateFrom, conf.Paths.DBFile)
+	},
+}
+
+func init() {
+	migrateDBCmd.Flags().StringVarP(&migrateFrom, "from", "f", "", "path of the JSON db file to import (default is 'db.json' next to 'paths.db-file').")
+}
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/skycoin/skywire-updater/pkg/api"
+)
+
+var (
+	rpcAddr       string
+	rollbackForce bool
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback <service> [version]",
+	Short: "rolls back a service to a previous version",
+	Long: `
+rollback asks a running skywire-updater to restore the binaries of a previous
+version of the given service from its backups. If no [version] is specified,
+the most recent backup of a version other than the current one is restored.
+The version rolled back from is not offered by the service's checker again,
+unless it is explicitly updated to. As with updates, pinned services cannot be
+rolled back to other versions, and rollbacks outside of the service's
+maintenance windows are rejected unless --force is set.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(_ *cobra.Command, args []string) {
+		var version string
+		if len(args) > 1 {
+			version = args[1]
+		}
+		client, err := api.DialRPC(rpcAddr)
+		if err != nil {
+			log.WithError(err).Fatalf("failed to dial skywire-updater at '%s'", rpcAddr)
+		}
+		defer func() {
+			if err := client.Close(); err != nil {
+				log.WithError(err).Error("failed to close rpc client")
+			}
+		}()
+		out, err := client.Rollback(args[0], version, rollbackForce)
+		if err != nil {
+			log.WithError(err).Fatalf("failed to roll back '%s'", args[0])
+		}
+		restored := out.Version
+		if restored == "" {
+			restored = "unknown version"
+		}
+		if out.RestartError != "" {
+			log.Fatalf("Rolled back '%s' to %s, but %s", args[0], restored, out.RestartError)
+		}
+		log.Infof("Rolled back '%s' to %s", args[0], restored)
+	},
+}
+
+func init() {
+	rollbackCmd.Flags().StringVarP(&rpcAddr, "addr", "a", ":7280", "address of the skywire-updater RPC interface.")
+	rollbackCmd.Flags().BoolVarP(&rollbackForce, "force", "f", false, "roll back even if outside of the service's maintenance windows.")
+}
@@ -62,8 +62,8 @@ directories are searched in order:
 			return
 		}
 
-		log.Infof("db path: '%s'", conf.Paths.DBFile)
-		db, err := store.NewJSON(conf.Paths.DBFile)
+		log.Infof("db path: '%s' (%s)", conf.Paths.DBFile, conf.Paths.DBType)
+		db, err := store.New(conf.Paths.DBType, conf.Paths.DBFile)
 		if err != nil {
 			log.WithError(err).Fatalln("failed to load db")
 			return
@@ -93,6 +93,8 @@ directories are searched in order:
 func Execute() {
 
 	RootCmd.AddCommand(initConfigCmd)
+	RootCmd.AddCommand(migrateDBCmd)
+	RootCmd.AddCommand(rollbackCmd)
 
 	if err := RootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -8,9 +8,10 @@ require (
 	github.com/skycoin/skywire v0.1.2-0.20190418071242-c3a15be79321
 	github.com/spf13/cobra v0.0.3
 	github.com/stretchr/testify v1.3.0
+	go.etcd.io/bbolt v1.3.5
 	golang.org/x/crypto v0.0.0-20190418165655-df01cb2cc480 // indirect
 	golang.org/x/net v0.0.0-20190419010253-1f3472d942ba // indirect
-	golang.org/x/sys v0.0.0-20190418153312-f0ce4c0180be // indirect
+	golang.org/x/sys v0.0.0-20200202164722-d101bd2416d5 // indirect
 	golang.org/x/tools v0.0.0-20190418235243-4796d4bd3df0 // indirect
 	gopkg.in/yaml.v2 v2.2.2
 )
@@ -7,18 +7,37 @@ import (
 	"github.com/go-chi/chi"
 	"github.com/skycoin/skycoin/src/util/logging"
 
+	"github.com/skycoin/skywire-updater/pkg/store"
 	"github.com/skycoin/skywire-updater/pkg/update"
 )
 
 const rpcPrefix = "updater"
 
+// Update triggers of the interfaces.
+const (
+	TriggerREST = "rest"
+	TriggerRPC  = "rpc"
+)
+
 var log = logging.MustGetLogger("api")
 
 // Gateway provides the API gateway.
 type Gateway interface {
 	Services() []string
 	Check(ctx context.Context, srvName string) (*update.Release, error)
-	Update(ctx context.Context, srvName, toVersion string) (bool, error)
+	LastCheck(srvName string) (*update.CheckStatus, error)
+	Update(ctx context.Context, srvName, toVersion string, opts update.UpdateOptions) (bool, error)
+	StartUpdate(srvName, toVersion string, opts update.UpdateOptions) (*update.Job, error)
+	Job(id string) (*update.JobInfo, error)
+	Jobs(srvName string) ([]update.JobInfo, error)
+	CancelJob(id string) error
+	Run(id string) (*update.Output, error)
+	Runs(srvName string) ([]update.RunInfo, error)
+	History(srvName string) ([]store.HistoryEntry, error)
+	Rollback(ctx context.Context, srvName, toVersion string, opts update.UpdateOptions) (string, error)
+	Backups(srvName string) ([]update.Backup, error)
+	Channel(srvName string) (*update.ChannelInfo, error)
+	SetChannel(srvName, channel string) error
 }
 
 // Handle makes a http.Handler from a Gateway implementation.
@@ -3,10 +3,13 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi"
 
+	"github.com/skycoin/skywire-updater/pkg/store"
 	"github.com/skycoin/skywire-updater/pkg/update"
 )
 
@@ -14,7 +17,19 @@ func handleREST(g Gateway) http.Handler {
 	r := chi.NewRouter()
 	r.Get("/services", services(g))
 	r.Get("/services/{srv}/check", checkService(g))
+	r.Get("/services/{srv}/last-check", lastCheckService(g))
 	r.Post("/services/{srv}/update/{ver}", updateService(g))
+	r.Get("/services/{srv}/history", serviceHistory(g))
+	r.Get("/services/{srv}/backups", serviceBackups(g))
+	r.Post("/services/{srv}/rollback", rollbackService(g))
+	r.Get("/services/{srv}/channel", serviceChannel(g))
+	r.Post("/services/{srv}/channel/{channel}", setServiceChannel(g))
+	r.Get("/jobs", listJobs(g))
+	r.Get("/jobs/{job}", getJob(g))
+	r.Post("/jobs/{job}/cancel", cancelJob(g))
+	r.Get("/runs", listRuns(g))
+	r.Get("/runs/{run}", getRun(g))
+	r.Get("/runs/{run}/stream", streamRun(g))
 	return r
 }
 
@@ -31,38 +46,296 @@ func checkService(g Gateway) http.HandlerFunc {
 		)
 		release, err := g.Check(r.Context(), pSrv)
 		if err != nil {
-			if err == update.ErrServiceNotFound {
-				writeJSON(w, http.StatusNotFound, err)
-				return
-			}
-			writeJSON(w, http.StatusInternalServerError, err)
+			writeError(w, err)
 			return
 		}
 		writeJSON(w, http.StatusOK, release)
 	}
 }
 
-func updateService(g Gateway) http.HandlerFunc {
+func lastCheckService(g Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var (
 			pSrv = chi.URLParam(r, "srv")
-			pVer = chi.URLParam(r, "ver")
 		)
-		ok, err := g.Update(r.Context(), pSrv, pVer)
+		status, err := g.LastCheck(pSrv)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	}
+}
+
+func updateService(g Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			pSrv   = chi.URLParam(r, "srv")
+			pVer   = chi.URLParam(r, "ver")
+			qForce = r.URL.Query().Get("force")
+		)
+		force, err := parseBool(qForce)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, err)
+			return
+		}
+		job, err := g.StartUpdate(pSrv, pVer, update.UpdateOptions{Force: force, Trigger: TriggerREST})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, job.Info(false))
+	}
+}
+
+func listJobs(g Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			qSrv = r.URL.Query().Get("service")
+		)
+		jobs, err := g.Jobs(qSrv)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, jobs)
+	}
+}
+
+func getJob(g Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			pJob = chi.URLParam(r, "job")
+		)
+		job, err := g.Job(pJob)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+func cancelJob(g Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			pJob = chi.URLParam(r, "job")
+		)
+		if err := g.CancelJob(pJob); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, true)
+	}
+}
+
+func listRuns(g Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			qSrv = r.URL.Query().Get("service")
+		)
+		runs, err := g.Runs(qSrv)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, runs)
+	}
+}
+
+func getRun(g Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			pRun = chi.URLParam(r, "run")
+		)
+		run, err := g.Run(pRun)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		transcript, err := run.Transcript()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, update.RunTranscript{RunInfo: run.Info(), Output: transcript})
+	}
+}
+
+// streamRun streams the output of a run as server-sent events. The most recent
+// lines are sent first, followed by lines as they are captured. Each line is
+// sent as a "line" event, and an "end" event (with the run's details) is sent
+// once the run ends. An "error" event is sent if the client falls too far
+// behind, in which case the full transcript can be obtained once the run ends.
+func streamRun(g Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			pRun = chi.URLParam(r, "run")
+		)
+		run, err := g.Run(pRun)
 		if err != nil {
-			if err == update.ErrServiceNotFound {
-				writeJSON(w, http.StatusNotFound, err)
+			writeError(w, err)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeJSON(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+			return
+		}
+		backlog, lines := run.Subscribe()
+		defer run.Unsubscribe(lines)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		for _, line := range backlog {
+			writeEvent(w, "line", line)
+		}
+		flusher.Flush()
+
+		for {
+			select {
+			case <-r.Context().Done():
 				return
+			case line, ok := <-lines:
+				switch {
+				case ok:
+					writeEvent(w, "line", line)
+				case run.Info().EndedAt != nil:
+					writeEvent(w, "end", run.Info())
+				default:
+					writeEvent(w, "error", map[string]string{"message": "stream fell behind, output was skipped"})
+				}
+				flusher.Flush()
+				if !ok {
+					return
+				}
 			}
-			writeJSON(w, http.StatusInternalServerError, err)
+		}
+	}
+}
+
+// writes a server-sent event with a json payload.
+func writeEvent(w http.ResponseWriter, event string, v interface{}) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		log.WithError(err).Fatal()
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, raw); err != nil {
+		log.WithError(err).Debug("Failed to write event.")
+	}
+}
+
+func serviceHistory(g Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			pSrv = chi.URLParam(r, "srv")
+		)
+		history, err := g.History(pSrv)
+		if err != nil {
+			writeError(w, err)
 			return
 		}
-		if !ok {
-			writeJSON(w, http.StatusInternalServerError, errors.New("update failed"))
+		if history == nil {
+			history = []store.HistoryEntry{}
+		}
+		writeJSON(w, http.StatusOK, history)
+	}
+}
+
+func serviceBackups(g Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			pSrv = chi.URLParam(r, "srv")
+		)
+		backups, err := g.Backups(pSrv)
+		if err != nil {
+			writeError(w, err)
 			return
 		}
-		writeJSON(w, http.StatusOK, ok)
+		if backups == nil {
+			backups = []update.Backup{}
+		}
+		writeJSON(w, http.StatusOK, backups)
+	}
+}
+
+func rollbackService(g Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			pSrv   = chi.URLParam(r, "srv")
+			qVer   = r.URL.Query().Get("version")
+			qForce = r.URL.Query().Get("force")
+		)
+		force, err := parseBool(qForce)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, err)
+			return
+		}
+		out, err := rollbackOut(g.Rollback(r.Context(), pSrv, qVer, update.UpdateOptions{Force: force, Trigger: TriggerREST}))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+func serviceChannel(g Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			pSrv = chi.URLParam(r, "srv")
+		)
+		channel, err := g.Channel(pSrv)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, channel)
+	}
+}
+
+func setServiceChannel(g Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			pSrv     = chi.URLParam(r, "srv")
+			pChannel = chi.URLParam(r, "channel")
+		)
+		if err := g.SetChannel(pSrv, pChannel); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, true)
+	}
+}
+
+// writes an error with a http status code which depends on the error.
+func writeError(w http.ResponseWriter, err error) {
+	if _, ok := err.(*update.WindowError); ok {
+		writeJSON(w, http.StatusConflict, err)
+		return
+	}
+	switch err {
+	case update.ErrServiceNotFound, update.ErrJobNotFound, update.ErrRunNotFound, update.ErrBackupNotFound,
+		update.ErrChannelNotFound:
+		writeJSON(w, http.StatusNotFound, err)
+	case update.ErrServicePinned, update.ErrJobNotRunning, update.ErrChannelsNotSupported:
+		writeJSON(w, http.StatusConflict, err)
+	default:
+		writeJSON(w, http.StatusInternalServerError, err)
+	}
+}
+
+// parses an optional boolean query value.
+func parseBool(v string) (bool, error) {
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean value '%s'", v)
 	}
+	return b, nil
 }
 
 // writes a json object on a http.ResponseWriter with the given code,
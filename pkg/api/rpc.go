@@ -6,6 +6,7 @@ import (
 	"net/rpc"
 	"time"
 
+	"github.com/skycoin/skywire-updater/pkg/store"
 	"github.com/skycoin/skywire-updater/pkg/update"
 )
 
@@ -44,14 +45,28 @@ func (r *RPC) Check(in *CheckIn, out *update.Release) error {
 		defer cancel()
 	}
 	release, err := r.g.Check(ctx, in.Service)
+	if err != nil {
+		return err
+	}
 	*out = *release
-	return err
+	return nil
+}
+
+// LastCheck obtains the cached result of the most recent check of the given service.
+func (r *RPC) LastCheck(srvName *string, out *update.CheckStatus) error {
+	status, err := r.g.LastCheck(*srvName)
+	if err != nil {
+		return err
+	}
+	*out = *status
+	return nil
 }
 
 // UpdateIn is the input for Update.
 type UpdateIn struct {
 	Service   string
 	ToVersion string
+	Force     bool // Update even if outside of the service's maintenance windows.
 	Deadline  time.Time
 }
 
@@ -63,10 +78,123 @@ func (r *RPC) Update(in *UpdateIn, ok *bool) (err error) {
 		ctx, cancel = context.WithDeadline(ctx, in.Deadline)
 		defer cancel()
 	}
-	*ok, err = r.g.Update(ctx, in.Service, in.ToVersion)
+	*ok, err = r.g.Update(ctx, in.Service, in.ToVersion, update.UpdateOptions{Force: in.Force, Trigger: TriggerRPC})
+	return err
+}
+
+// StartUpdate starts a job which updates the given service.
+func (r *RPC) StartUpdate(in *UpdateIn, out *update.JobInfo) error {
+	job, err := r.g.StartUpdate(in.Service, in.ToVersion, update.UpdateOptions{Force: in.Force, Trigger: TriggerRPC})
+	if err != nil {
+		return err
+	}
+	*out = job.Info(false)
+	return nil
+}
+
+// Job obtains the job of the given ID, including its output.
+func (r *RPC) Job(id *string, out *update.JobInfo) error {
+	job, err := r.g.Job(*id)
+	if err != nil {
+		return err
+	}
+	*out = *job
+	return nil
+}
+
+// Jobs lists the recent jobs of the given service (or of all services if empty).
+func (r *RPC) Jobs(srvName *string, out *[]update.JobInfo) (err error) {
+	*out, err = r.g.Jobs(*srvName)
+	return err
+}
+
+// CancelJob cancels the job of the given ID.
+func (r *RPC) CancelJob(id *string, _ *struct{}) error {
+	return r.g.CancelJob(*id)
+}
+
+// Runs lists the recent runs of the given service (or of all services if empty).
+func (r *RPC) Runs(srvName *string, out *[]update.RunInfo) (err error) {
+	*out, err = r.g.Runs(*srvName)
+	return err
+}
+
+// Run obtains the run of the given ID, including its full transcript.
+func (r *RPC) Run(id *string, out *update.RunTranscript) error {
+	run, err := r.g.Run(*id)
+	if err != nil {
+		return err
+	}
+	transcript, err := run.Transcript()
+	if err != nil {
+		return err
+	}
+	*out = update.RunTranscript{RunInfo: run.Info(), Output: transcript}
+	return nil
+}
+
+// History obtains the update attempts of the given service, oldest first.
+func (r *RPC) History(srvName *string, out *[]store.HistoryEntry) (err error) {
+	*out, err = r.g.History(*srvName)
+	return err
+}
+
+// RollbackIn is the input for Rollback.
+type RollbackIn struct {
+	Service   string
+	ToVersion string // The most recent suitable backup is restored if empty.
+	Force     bool   // Roll back even if outside of the service's maintenance windows.
+}
+
+// RollbackOut is the output of Rollback.
+type RollbackOut struct {
+	Version      string `json:"version"`                 // Restored version.
+	RestartError string `json:"restart_error,omitempty"` // Why the service could not be restarted after its binaries were restored.
+}
+
+// rollbackOut makes the output of a rollback. A failure to restart the service
+// is reported in the output, as its binaries were restored nonetheless.
+func rollbackOut(restored string, err error) (RollbackOut, error) {
+	if rErr, ok := err.(*update.RestartError); ok && restored != "" {
+		return RollbackOut{Version: restored, RestartError: rErr.Error()}, nil
+	}
+	return RollbackOut{Version: restored}, err
+}
+
+// Rollback restores a previous version of the given service, and outputs the
+// restored version.
+func (r *RPC) Rollback(in *RollbackIn, out *RollbackOut) (err error) {
+	*out, err = rollbackOut(r.g.Rollback(context.Background(), in.Service, in.ToVersion, update.UpdateOptions{Force: in.Force, Trigger: TriggerRPC}))
+	return err
+}
+
+// Backups lists the backups of the given service, newest first.
+func (r *RPC) Backups(srvName *string, out *[]update.Backup) (err error) {
+	*out, err = r.g.Backups(*srvName)
 	return err
 }
 
+// Channel obtains the release channel of the given service.
+func (r *RPC) Channel(srvName *string, out *update.ChannelInfo) error {
+	channel, err := r.g.Channel(*srvName)
+	if err != nil {
+		return err
+	}
+	*out = *channel
+	return nil
+}
+
+// SetChannelIn is the input for SetChannel.
+type SetChannelIn struct {
+	Service string
+	Channel string
+}
+
+// SetChannel switches the given service to a release channel.
+func (r *RPC) SetChannel(in *SetChannelIn, _ *struct{}) error {
+	return r.g.SetChannel(in.Service, in.Channel)
+}
+
 // RPCClient calls RPC.
 type RPCClient struct {
 	*rpc.Client
@@ -105,9 +233,89 @@ func (rc *RPCClient) Check(srvName string, deadline time.Time) (update.Release,
 	return out, err
 }
 
+// LastCheck calls LastCheck.
+func (rc *RPCClient) LastCheck(srvName string) (update.CheckStatus, error) {
+	var out update.CheckStatus
+	err := rc.Call("LastCheck", &srvName, &out)
+	return out, err
+}
+
 // Update calls Update.
-func (rc *RPCClient) Update(srvName, toVersion string, deadline time.Time) (bool, error) {
+func (rc *RPCClient) Update(srvName, toVersion string, force bool, deadline time.Time) (bool, error) {
 	var ok bool
-	err := rc.Call("Update", &UpdateIn{Service: srvName, ToVersion: toVersion, Deadline: deadline}, &ok)
+	err := rc.Call("Update", &UpdateIn{Service: srvName, ToVersion: toVersion, Force: force, Deadline: deadline}, &ok)
 	return ok, err
 }
+
+// StartUpdate calls StartUpdate.
+func (rc *RPCClient) StartUpdate(srvName, toVersion string, force bool) (update.JobInfo, error) {
+	var out update.JobInfo
+	err := rc.Call("StartUpdate", &UpdateIn{Service: srvName, ToVersion: toVersion, Force: force}, &out)
+	return out, err
+}
+
+// Job calls Job.
+func (rc *RPCClient) Job(id string) (update.JobInfo, error) {
+	var out update.JobInfo
+	err := rc.Call("Job", &id, &out)
+	return out, err
+}
+
+// Jobs calls Jobs.
+func (rc *RPCClient) Jobs(srvName string) ([]update.JobInfo, error) {
+	var out []update.JobInfo
+	err := rc.Call("Jobs", &srvName, &out)
+	return out, err
+}
+
+// CancelJob calls CancelJob.
+func (rc *RPCClient) CancelJob(id string) error {
+	return rc.Call("CancelJob", &id, &struct{}{})
+}
+
+// Runs calls Runs.
+func (rc *RPCClient) Runs(srvName string) ([]update.RunInfo, error) {
+	var out []update.RunInfo
+	err := rc.Call("Runs", &srvName, &out)
+	return out, err
+}
+
+// Run calls Run.
+func (rc *RPCClient) Run(id string) (update.RunTranscript, error) {
+	var out update.RunTranscript
+	err := rc.Call("Run", &id, &out)
+	return out, err
+}
+
+// History calls History.
+func (rc *RPCClient) History(srvName string) ([]store.HistoryEntry, error) {
+	var out []store.HistoryEntry
+	err := rc.Call("History", &srvName, &out)
+	return out, err
+}
+
+// Rollback calls Rollback.
+func (rc *RPCClient) Rollback(srvName, toVersion string, force bool) (RollbackOut, error) {
+	var out RollbackOut
+	err := rc.Call("Rollback", &RollbackIn{Service: srvName, ToVersion: toVersion, Force: force}, &out)
+	return out, err
+}
+
+// Backups calls Backups.
+func (rc *RPCClient) Backups(srvName string) ([]update.Backup, error) {
+	var out []update.Backup
+	err := rc.Call("Backups", &srvName, &out)
+	return out, err
+}
+
+// Channel calls Channel.
+func (rc *RPCClient) Channel(srvName string) (update.ChannelInfo, error) {
+	var out update.ChannelInfo
+	err := rc.Call("Channel", &srvName, &out)
+	return out, err
+}
+
+// SetChannel calls SetChannel.
+func (rc *RPCClient) SetChannel(srvName, channel string) error {
+	return rc.Call("SetChannel", &SetChannelIn{Service: srvName, Channel: channel}, &struct{}{})
+}
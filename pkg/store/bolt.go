@@ -0,0 +1,131 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	lastUpdatesBucket = []byte("last_updates")
+	historyBucket     = []byte("history")
+)
+
+// Bolt implements Store using a bbolt database file.
+// Last updates are kept in the "last_updates" bucket (keyed by service name),
+// and each service's update history is kept in a nested bucket of the
+// "history" bucket (keyed by sequence number).
+type Bolt struct {
+	db  *bbolt.DB
+	log *logging.Logger
+}
+
+// NewBolt creates a new Bolt Store implementation.
+func NewBolt(filePath string) (*Bolt, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create db file: %s", err.Error())
+	}
+	db, err := bbolt.Open(filePath, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open '%s': %s", filePath, err.Error())
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{lastUpdatesBucket, historyBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close() //nolint:errcheck
+		return nil, fmt.Errorf("failed to prepare '%s': %s", filePath, err.Error())
+	}
+	return &Bolt{
+		db:  db,
+		log: logging.MustGetLogger("store(bolt)"),
+	}, nil
+}
+
+// ServiceLastUpdate obtains the last update for a given service.
+func (b *Bolt) ServiceLastUpdate(srvName string) Update {
+	var update Update
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		raw := tx.Bucket(lastUpdatesBucket).Get([]byte(srvName))
+		if raw == nil {
+			return nil
+		}
+		return json.Unmarshal(raw, &update)
+	})
+	if err != nil {
+		b.log.WithError(err).Errorf("failed to read last update of '%s'", srvName)
+		return Update{}
+	}
+	return update
+}
+
+// SetServiceLastUpdate sets a last update for a given service.
+func (b *Bolt) SetServiceLastUpdate(srvName string, last Update) error {
+	raw, err := json.Marshal(last)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(lastUpdatesBucket).Put([]byte(srvName), raw)
+	})
+}
+
+// AppendHistory appends an update attempt to the history.
+func (b *Bolt) AppendHistory(entry HistoryEntry) error {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		bkt, err := tx.Bucket(historyBucket).CreateBucketIfNotExists([]byte(entry.Service))
+		if err != nil {
+			return err
+		}
+		seq, err := bkt.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		return bkt.Put(key, raw)
+	})
+}
+
+// ServiceHistory obtains the update attempts of a given service, oldest first.
+func (b *Bolt) ServiceHistory(srvName string) []HistoryEntry {
+	var out []HistoryEntry
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket(historyBucket).Bucket([]byte(srvName))
+		if bkt == nil {
+			return nil
+		}
+		return bkt.ForEach(func(_, raw []byte) error {
+			var entry HistoryEntry
+			if err := json.Unmarshal(raw, &entry); err != nil {
+				return err
+			}
+			out = append(out, entry)
+			return nil
+		})
+	})
+	if err != nil {
+		b.log.WithError(err).Errorf("failed to read history of '%s'", srvName)
+	}
+	return out
+}
+
+// Close closes the database.
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
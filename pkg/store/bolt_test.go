@@ -0,0 +1,68 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBolt(t *testing.T) {
+	dir, rm := prepareDir(t)
+	defer rm()
+	dbPath := filepath.Join(dir, "db.bolt")
+
+	b, err := NewBolt(dbPath)
+	require.NoError(t, err)
+	require.True(t, b.ServiceLastUpdate("a").IsEmpty())
+	require.Empty(t, b.ServiceHistory("a"))
+
+	last := Update{Tag: "v1.0", Timestamp: 1}
+	require.NoError(t, b.SetServiceLastUpdate("a", last))
+	entries := []HistoryEntry{
+		{Service: "a", ToVersion: "v1.0", StartTime: 1, EndTime: 2, Outcome: OutcomeSucceeded, Trigger: "rest"},
+		{Service: "b", ToVersion: "v2.0", StartTime: 3, EndTime: 4, Outcome: OutcomeFailed, Error: "failed", Trigger: "policy"},
+		{Service: "a", FromVersion: "v1.0", ToVersion: "v1.1", StartTime: 5, EndTime: 6, Outcome: OutcomeSucceeded, Trigger: "rpc"},
+	}
+	for _, e := range entries {
+		require.NoError(t, b.AppendHistory(e))
+	}
+	require.NoError(t, b.Close())
+
+	b, err = NewBolt(dbPath)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, b.Close())
+	}()
+	require.Equal(t, last, b.ServiceLastUpdate("a"))
+	require.Equal(t, []HistoryEntry{entries[0], entries[2]}, b.ServiceHistory("a"))
+	require.Equal(t, []HistoryEntry{entries[1]}, b.ServiceHistory("b"))
+}
+
+func TestImportJSON(t *testing.T) {
+	dir, rm := prepareDir(t)
+	defer rm()
+	jsonPath := filepath.Join(dir, "db.json")
+
+	j, err := NewJSON(jsonPath)
+	require.NoError(t, err)
+	last := Update{Tag: "v1.0", Timestamp: 1}
+	entry := HistoryEntry{Service: "a", ToVersion: "v1.0", StartTime: 1, EndTime: 2, Outcome: OutcomeSucceeded}
+	require.NoError(t, j.SetServiceLastUpdate("a", last))
+	require.NoError(t, j.AppendHistory(entry))
+	require.NoError(t, j.Close())
+
+	b, err := NewBolt(filepath.Join(dir, "db.bolt"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, b.Close())
+	}()
+	n, err := ImportJSON(b, jsonPath)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, last, b.ServiceLastUpdate("a"))
+	require.Equal(t, []HistoryEntry{entry}, b.ServiceHistory("a"))
+
+	_, err = ImportJSON(b, filepath.Join(dir, "missing.json"))
+	require.Error(t, err)
+}
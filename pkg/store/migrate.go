@@ -0,0 +1,38 @@
+package store
+
+import (
+	"fmt"
+	"os"
+)
+
+// ImportJSON imports the last updates and the update history of the JSON
+// store at the given file path into the destination store.
+func ImportJSON(dst Store, jsonPath string) (int, error) {
+	if _, err := os.Stat(jsonPath); err != nil {
+		return 0, fmt.Errorf("failed to access '%s': %s", jsonPath, err.Error())
+	}
+	src, err := NewJSON(jsonPath)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := src.Close(); err != nil {
+			src.log.WithError(err).Warn("failed to close db")
+		}
+	}()
+
+	src.mu.RLock()
+	defer src.mu.RUnlock()
+
+	for srvName, last := range src.data {
+		if err := dst.SetServiceLastUpdate(srvName, last); err != nil {
+			return 0, fmt.Errorf("failed to import last update of '%s': %s", srvName, err.Error())
+		}
+	}
+	for i, entry := range src.history {
+		if err := dst.AppendHistory(entry); err != nil {
+			return len(src.data), fmt.Errorf("failed to import history entry %d: %s", i, err.Error())
+		}
+	}
+	return len(src.data), nil
+}
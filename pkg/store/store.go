@@ -1,9 +1,9 @@
 package store
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"sync"
@@ -13,8 +13,32 @@ import (
 
 // Update represents an update entry.
 type Update struct {
-	Tag       string `json:"tag,omitempty"`
-	Timestamp int64  `json:"timestamp"`
+	Tag        string   `json:"tag,omitempty"`
+	Timestamp  int64    `json:"timestamp"`
+	RolledBack []string `json:"rolled_back,omitempty"` // Versions which were rolled back from, and are not offered again.
+	Channel    string   `json:"channel,omitempty"`     // Release channel switched to (overrides the configured channel).
+	Version    string   `json:"version,omitempty"`     // Installed version, as last detected.
+	Commit     string   `json:"commit,omitempty"`      // Commit the service is known to be at (recorded by the git-ref checker).
+	Digest     string   `json:"digest,omitempty"`      // Image digest the service is known to be at (recorded by the oci-registry checker).
+}
+
+// Installed returns the installed version, which is the detected version if
+// any, or else the version last updated or rolled back to.
+func (u Update) Installed() string {
+	if u.Version != "" {
+		return u.Version
+	}
+	return u.Tag
+}
+
+// IsRolledBack checks whether the given version was rolled back from.
+func (u Update) IsRolledBack(version string) bool {
+	for _, v := range u.RolledBack {
+		if v == version {
+			return true
+		}
+	}
+	return false
 }
 
 // IsEmpty checks whether the update is empty.
@@ -22,24 +46,85 @@ func (u Update) IsEmpty() bool {
 	return u.Tag == "" && u.Timestamp == 0
 }
 
+// Outcome is the outcome of an update attempt.
+type Outcome string
+
+const (
+	// OutcomeSucceeded is the outcome of an update that succeeded.
+	OutcomeSucceeded = Outcome("succeeded")
+
+	// OutcomeFailed is the outcome of an update that failed.
+	OutcomeFailed = Outcome("failed")
+)
+
+// HistoryEntry represents an update attempt of a service.
+type HistoryEntry struct {
+	Service     string  `json:"service"`
+	FromVersion string  `json:"from_version,omitempty"`
+	ToVersion   string  `json:"to_version,omitempty"`
+	StartTime   int64   `json:"start_time"`
+	EndTime     int64   `json:"end_time"`
+	Outcome     Outcome `json:"outcome"`
+	Error       string  `json:"error,omitempty"`
+	Trigger     string  `json:"trigger"` // What triggered the update.
+	Rollback    bool    `json:"rollback,omitempty"`
+}
+
 // Store represents a database implementation.
 type Store interface {
 	ServiceLastUpdate(srvName string) Update
-	SetServiceLastUpdate(srvName string, last Update)
+	SetServiceLastUpdate(srvName string, last Update) error
+	AppendHistory(entry HistoryEntry) error
+	ServiceHistory(srvName string) []HistoryEntry
 	Close() error
 }
 
+// Type represents a Store implementation type.
+type Type string
+
+const (
+	// JSONType is the type of the JSON Store implementation.
+	JSONType = Type("json")
+
+	// BoltType is the type of the Bolt Store implementation.
+	BoltType = Type("bolt")
+)
+
+var types = []Type{
+	JSONType,
+	BoltType,
+}
+
+// New creates a new Store implementation of given type.
+func New(t Type, filePath string) (Store, error) {
+	switch t {
+	case JSONType:
+		return NewJSON(filePath)
+	case BoltType:
+		return NewBolt(filePath)
+	default:
+		return nil, fmt.Errorf("invalid db type '%s' when expecting: %v", t, types)
+	}
+}
+
 // JSON implements Store.
+// The db file is replaced atomically on each write, and the previous version is
+// kept as a backup (at the db file path with a ".bak" suffix) to recover from.
+// The update history is appended to a separate JSON lines file, which is
+// located at the db file path with a ".history" suffix.
 type JSON struct {
-	*os.File
-	data map[string]Update // key: srvName, value: Update
-	mu   sync.RWMutex
-	log  *logging.Logger
+	path    string
+	hist    *os.File
+	data    map[string]Update // key: srvName, value: Update
+	history []HistoryEntry
+	mu      sync.RWMutex
+	log     *logging.Logger
 }
 
 // NewJSON creates a new JSON Store implementation.
 func NewJSON(filePath string) (*JSON, error) {
 	db := &JSON{
+		path: filePath,
 		data: make(map[string]Update),
 		log:  logging.MustGetLogger("store(JSON)"),
 	}
@@ -48,19 +133,66 @@ func NewJSON(filePath string) (*JSON, error) {
 		return nil, fmt.Errorf("failed to create db file: %s", err.Error())
 	}
 
-	f, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE, 0600)
+	if err := readJSONFile(filePath, &db.data); err != nil {
+		db.log.WithError(err).Warnf("failed to read '%s', recovering from backup", filePath)
+		db.data = make(map[string]Update)
+		if _, bErr := os.Stat(db.backupPath()); bErr != nil {
+			return nil, fmt.Errorf("failed to read '%s' (%s) and its backup (%s)", filePath, err.Error(), bErr.Error())
+		}
+		if bErr := readJSONFile(db.backupPath(), &db.data); bErr != nil {
+			return nil, fmt.Errorf("failed to read '%s' (%s) and its backup (%s)", filePath, err.Error(), bErr.Error())
+		}
+		if err := writeJSONFileAtomic(filePath, "", &db.data); err != nil {
+			return nil, fmt.Errorf("failed to restore '%s' from backup: %s", filePath, err.Error())
+		}
+	}
+
+	histPath := filePath + ".history"
+	hf, err := os.OpenFile(histPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
 	if err != nil {
-		return nil, fmt.Errorf("failed to recreate state: %s", err.Error())
+		return nil, fmt.Errorf("failed to open history: %s", err.Error())
 	}
-	db.File = f
+	db.hist = hf
 
-	if err := json.NewDecoder(f).Decode(&db.data); err != nil && err != io.EOF {
-		return nil, fmt.Errorf("failed to read '%s': %s", filePath, err.Error())
+	sc := bufio.NewScanner(hf)
+	sc.Buffer(nil, 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		var entry HistoryEntry
+		if err := json.Unmarshal(sc.Bytes(), &entry); err != nil {
+			// An interrupted append may leave a partial line behind.
+			db.log.WithError(err).Warnf("skipping invalid line %d of '%s'", line, histPath)
+			continue
+		}
+		db.history = append(db.history, entry)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %s", histPath, err.Error())
+	}
+	if err := terminateLine(hf); err != nil {
+		return nil, fmt.Errorf("failed to repair '%s': %s", histPath, err.Error())
 	}
 
 	return db, nil
 }
 
+// terminateLine ensures that the file ends with a newline, so that appends
+// do not continue a partial line.
+func terminateLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	_, err = f.Write([]byte{'\n'})
+	return err
+}
+
 // ServiceLastUpdate obtains the last update for a given service..
 func (j *JSON) ServiceLastUpdate(srvName string) Update {
 	j.mu.RLock()
@@ -75,18 +207,61 @@ func (j *JSON) ServiceLastUpdate(srvName string) Update {
 }
 
 // SetServiceLastUpdate sets a last update for a given service.
-func (j *JSON) SetServiceLastUpdate(srvName string, last Update) {
+func (j *JSON) SetServiceLastUpdate(srvName string, last Update) error {
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
+	prev, ok := j.data[srvName]
 	j.data[srvName] = last
-	if err := j.Truncate(0); err != nil {
-		j.log.WithError(err).Fatal()
+	if err := writeJSONFileAtomic(j.path, j.backupPath(), &j.data); err != nil {
+		if ok {
+			j.data[srvName] = prev
+		} else {
+			delete(j.data, srvName)
+		}
+		return fmt.Errorf("failed to write '%s': %s", j.path, err.Error())
 	}
-	if _, err := j.Seek(0, 0); err != nil {
-		j.log.WithError(err).Fatal()
+	return nil
+}
+
+func (j *JSON) backupPath() string {
+	return j.path + ".bak"
+}
+
+// AppendHistory appends an update attempt to the history.
+func (j *JSON) AppendHistory(entry HistoryEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := j.hist.Write(append(raw, '\n')); err != nil {
+		return err
 	}
-	if err := json.NewEncoder(j).Encode(&j.data); err != nil {
-		j.log.WithError(err).Fatal()
+	if err := j.hist.Sync(); err != nil {
+		return err
 	}
+	j.history = append(j.history, entry)
+	return nil
+}
+
+// ServiceHistory obtains the update attempts of a given service, oldest first.
+func (j *JSON) ServiceHistory(srvName string) []HistoryEntry {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	var out []HistoryEntry
+	for _, entry := range j.history {
+		if entry.Service == srvName {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+// Close closes the history file.
+func (j *JSON) Close() error {
+	return j.hist.Close()
 }
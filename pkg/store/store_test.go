@@ -5,12 +5,22 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 )
 
+func prepareDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	require.NoError(t, err)
+	rm := func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}
+	return dir, rm
+}
+
 func TestNewJSON(t *testing.T) {
 	type service struct {
 		Name   string
@@ -18,13 +28,9 @@ func TestNewJSON(t *testing.T) {
 	}
 	const srvCount = 100
 
-	f, err := ioutil.TempFile(os.TempDir(), "")
-	require.NoError(t, err)
-	require.NoError(t, f.Close())
-	defer func() {
-		require.NoError(t, os.Remove(f.Name()))
-	}()
-	j, err := NewJSON(f.Name())
+	dir, rm := prepareDir(t)
+	defer rm()
+	j, err := NewJSON(filepath.Join(dir, "db.json"))
 	require.NoError(t, err)
 	defer func() {
 		require.NoError(t, j.Close())
@@ -42,9 +48,93 @@ func TestNewJSON(t *testing.T) {
 		}
 	}
 	for _, srv := range services {
-		j.SetServiceLastUpdate(srv.Name, srv.Update)
+		require.NoError(t, j.SetServiceLastUpdate(srv.Name, srv.Update))
 	}
 	for i, srv := range services {
 		require.Equal(t, srv.Update, j.ServiceLastUpdate(srv.Name), i)
 	}
 }
+
+func TestJSON_History(t *testing.T) {
+	dir, rm := prepareDir(t)
+	defer rm()
+	dbPath := filepath.Join(dir, "db.json")
+
+	j, err := NewJSON(dbPath)
+	require.NoError(t, err)
+	require.Empty(t, j.ServiceHistory("a"))
+
+	entries := []HistoryEntry{
+		{Service: "a", ToVersion: "v1.0", StartTime: 1, EndTime: 2, Outcome: OutcomeSucceeded, Trigger: "rest"},
+		{Service: "b", ToVersion: "v2.0", StartTime: 3, EndTime: 4, Outcome: OutcomeFailed, Error: "failed", Trigger: "policy"},
+		{Service: "a", FromVersion: "v1.0", ToVersion: "v1.1", StartTime: 5, EndTime: 6, Outcome: OutcomeSucceeded, Trigger: "rpc"},
+	}
+	for _, e := range entries {
+		require.NoError(t, j.AppendHistory(e))
+	}
+	require.Equal(t, []HistoryEntry{entries[0], entries[2]}, j.ServiceHistory("a"))
+	require.NoError(t, j.Close())
+
+	// Simulate an interrupted append.
+	f, err := os.OpenFile(dbPath+".history", os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"service":"a","to_vers`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	j, err = NewJSON(dbPath)
+	require.NoError(t, err)
+	require.Equal(t, []HistoryEntry{entries[0], entries[2]}, j.ServiceHistory("a"))
+	require.Equal(t, []HistoryEntry{entries[1]}, j.ServiceHistory("b"))
+
+	// Appends after an interrupted append are kept.
+	require.NoError(t, j.AppendHistory(entries[1]))
+	require.NoError(t, j.Close())
+	j, err = NewJSON(dbPath)
+	require.NoError(t, err)
+	require.Equal(t, []HistoryEntry{entries[1], entries[1]}, j.ServiceHistory("b"))
+	require.NoError(t, j.Close())
+}
+
+func TestJSON_recovery(t *testing.T) {
+	dir, rm := prepareDir(t)
+	defer rm()
+	dbPath := filepath.Join(dir, "db.json")
+
+	v1 := Update{Tag: "v1.0", Timestamp: 1}
+	v2 := Update{Tag: "v2.0", Timestamp: 2}
+
+	j, err := NewJSON(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, j.SetServiceLastUpdate("a", v1))
+	require.NoError(t, j.SetServiceLastUpdate("a", v2))
+	require.NoError(t, j.Close())
+
+	// No temporary files are left behind.
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	require.ElementsMatch(t, []string{"db.json", "db.json.bak", "db.json.history"}, names)
+
+	// Simulate a corrupted db file.
+	require.NoError(t, ioutil.WriteFile(dbPath, []byte(`{"a":{"ta`), 0600))
+	j, err = NewJSON(dbPath)
+	require.NoError(t, err)
+	require.Equal(t, v1, j.ServiceLastUpdate("a"))
+	require.NoError(t, j.Close())
+
+	// The db file is restored from the backup.
+	j, err = NewJSON(dbPath)
+	require.NoError(t, err)
+	require.Equal(t, v1, j.ServiceLastUpdate("a"))
+	require.NoError(t, j.Close())
+
+	// No recovery is possible without a backup.
+	require.NoError(t, ioutil.WriteFile(dbPath, []byte(`{"a":{"ta`), 0600))
+	require.NoError(t, os.Remove(dbPath+".bak"))
+	_, err = NewJSON(dbPath)
+	require.Error(t, err)
+}
@@ -0,0 +1,417 @@
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+)
+
+// DefaultAssetPattern matches release assets such as
+// "skywire-v0.1.0-linux-amd64.tar.gz".
+const DefaultAssetPattern = "*{os}?{arch}.*"
+
+// signatureSuffixes are the suffixes of assets which hold signatures or
+// checksums of other assets, and are never picked for installation.
+var signatureSuffixes = []string{".sig", ".asc", ".pem", ".sha256"}
+
+// GitReleaseAsset is an asset of a github release.
+type GitReleaseAsset struct {
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	DownloadURL string `json:"browser_download_url"`
+}
+
+// GithubAssetUpdater updates a service by installing binaries from an asset of
+// the service's release on its forge (github, or another forge).
+type GithubAssetUpdater struct {
+	srvName string
+	c       ServiceConfig
+	forge   Forge
+	log     *logging.Logger
+}
+
+// NewGithubAssetUpdater creates a new GithubAssetUpdater.
+func NewGithubAssetUpdater(srvName string, c ServiceConfig) *GithubAssetUpdater {
+	if c.Updater.Asset == "" {
+		c.Updater.Asset = DefaultAssetPattern
+	}
+	if len(c.Updater.Binaries) == 0 && c.MainProcess != "" {
+		c.Updater.Binaries = []string{c.MainProcess}
+	}
+	au := &GithubAssetUpdater{
+		srvName: srvName,
+		c:       c,
+		log:     logging.MustGetLogger("asset-updater." + srvName),
+	}
+	au.forge = newServiceForge(au.log, c, c.Updater.BaseURL)
+	return au
+}
+
+// Update installs the binaries of the given release version (or of the latest
+// release if version is empty). The asset is always verified against the
+// checksum manifest of the release, which must be signed by the service's
+// trusted keys if it has any.
+func (au *GithubAssetUpdater) Update(ctx context.Context, version string) (bool, error) {
+	release, err := au.fetchRelease(ctx, version)
+	if err != nil {
+		return false, err
+	}
+	asset, err := au.pickAsset(release)
+	if err != nil {
+		return false, err
+	}
+
+	tmpDir, err := ioutil.TempDir("", "skywire-updater")
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			au.log.WithError(err).Warn("Failed to remove temporary directory.")
+		}
+	}()
+
+	sums, err := au.fetchChecksums(ctx, release, asset, tmpDir)
+	if err != nil {
+		return false, err
+	}
+	if au.c.Trust.Enabled() {
+		if err := au.verifySignatures(ctx, release, sums.manifest, sums.path, tmpDir); err != nil {
+			return false, err
+		}
+	}
+
+	progress(ctx, au.log, "Downloading '%s' of release '%s'.", asset.Name, release.TagName)
+	assetPath := filepath.Join(tmpDir, asset.Name)
+	if err := au.download(ctx, asset.DownloadURL, assetPath); err != nil {
+		return false, fmt.Errorf("failed to download '%s': %s", asset.Name, err)
+	}
+	if err := sums.verify(asset.Name, assetPath); err != nil {
+		return false, err
+	}
+	progress(ctx, au.log, "Verified sha256 checksum of '%s' against '%s'.", asset.Name, sums.manifest)
+
+	extractDir := filepath.Join(tmpDir, "extracted")
+	if err := extractBinaries(assetPath, extractDir, au.c.Updater.Binaries); err != nil {
+		return false, fmt.Errorf("failed to extract '%s': %s", asset.Name, err)
+	}
+
+	binDir := binDirFromContext(ctx, au.c.BinDir)
+	for _, bin := range au.c.Updater.Binaries {
+		progress(ctx, au.log, "Installing '%s' into '%s'.", bin, binDir)
+		if err := installBinary(filepath.Join(extractDir, bin), filepath.Join(binDir, bin)); err != nil {
+			return false, fmt.Errorf("failed to install '%s': %s", bin, err)
+		}
+		if au.c.Trust.Enabled() {
+			markVerified(ctx, bin)
+		}
+	}
+	return true, nil
+}
+
+func (au *GithubAssetUpdater) fetchRelease(ctx context.Context, version string) (*GitReleaseBody, error) {
+	var body *GitReleaseBody
+	var err error
+	if version == "" {
+		body, err = au.forge.LatestRelease(ctx)
+	} else {
+		body, err = au.forge.Release(ctx, version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch release '%s': %s", version, err)
+	}
+	return body, nil
+}
+
+// pickAsset picks the single asset of the release matching the asset pattern
+// for the host.
+func (au *GithubAssetUpdater) pickAsset(release *GitReleaseBody) (*GitReleaseAsset, error) {
+	pattern := strings.NewReplacer(
+		"{os}", runtime.GOOS,
+		"{arch}", runtime.GOARCH,
+		"{version}", release.TagName,
+	).Replace(au.c.Updater.Asset)
+
+	var picked []GitReleaseAsset
+	for _, asset := range release.Assets {
+		if isSignatureAsset(asset.Name) {
+			continue
+		}
+		ok, err := path.Match(pattern, asset.Name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asset pattern '%s': %s", pattern, err)
+		}
+		if ok {
+			picked = append(picked, asset)
+		}
+	}
+	switch len(picked) {
+	case 0:
+		return nil, fmt.Errorf("no asset of release '%s' matches '%s'", release.TagName, pattern)
+	case 1:
+		return &picked[0], nil
+	default:
+		names := make([]string, len(picked))
+		for i, asset := range picked {
+			names[i] = asset.Name
+		}
+		return nil, fmt.Errorf("multiple assets of release '%s' match '%s': %v", release.TagName, pattern, names)
+	}
+}
+
+// fetchChecksums downloads and parses the checksum manifest of the release.
+// A *ChecksumError is returned if the release has no manifest.
+func (au *GithubAssetUpdater) fetchChecksums(ctx context.Context, release *GitReleaseBody, asset *GitReleaseAsset, dir string) (*checksums, error) {
+	patterns := DefaultChecksumsPatterns
+	if au.c.Updater.Checksums != "" {
+		patterns = []string{au.c.Updater.Checksums}
+	}
+	for _, pattern := range patterns {
+		for _, a := range release.Assets {
+			if ok, _ := path.Match(pattern, a.Name); !ok || a.Name == asset.Name { //nolint:errcheck
+				continue
+			}
+			progress(ctx, au.log, "Downloading checksum manifest '%s'.", a.Name)
+			manifestPath := filepath.Join(dir, a.Name)
+			if err := au.download(ctx, a.DownloadURL, manifestPath); err != nil {
+				return nil, fmt.Errorf("failed to download '%s': %s", a.Name, err)
+			}
+			f, err := os.Open(manifestPath) //nolint:gosec
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			sums, err := parseChecksums(a.Name, f)
+			if err != nil {
+				return nil, err
+			}
+			sums.path = manifestPath
+			return sums, nil
+		}
+	}
+	return nil, &ChecksumError{Artifact: asset.Name}
+}
+
+// verifySignatures downloads the detached signatures of the given release file,
+// and verifies them against the service's trusted keys.
+func (au *GithubAssetUpdater) verifySignatures(ctx context.Context, release *GitReleaseBody, file, filePath, dir string) error {
+	sigName := au.c.Trust.signaturesName(file)
+	var sigAsset *GitReleaseAsset
+	for i, a := range release.Assets {
+		if a.Name == sigName {
+			sigAsset = &release.Assets[i]
+		}
+	}
+	if sigAsset == nil {
+		return &SignatureError{
+			File:      file,
+			Threshold: au.c.Trust.threshold(),
+			Reason:    fmt.Sprintf("release has no signature asset '%s'", sigName),
+		}
+	}
+
+	progress(ctx, au.log, "Downloading signatures '%s'.", sigName)
+	sigPath := filepath.Join(dir, sigName)
+	if err := au.download(ctx, sigAsset.DownloadURL, sigPath); err != nil {
+		return fmt.Errorf("failed to download '%s': %s", sigName, err)
+	}
+	sigs, err := ioutil.ReadFile(sigPath) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(filePath) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	if err := au.c.Trust.Verify(au.srvName, file, data, sigs); err != nil {
+		return err
+	}
+	progress(ctx, au.log, "Verified signatures of '%s' by trusted keys.", file)
+	return nil
+}
+
+func isSignatureAsset(name string) bool {
+	for _, suffix := range signatureSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (au *GithubAssetUpdater) download(ctx context.Context, url, dst string) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	au.forge.Authorize(req)
+	req.Header.Add("Accept", "application/octet-stream")
+	resp, err := forgeClient(au.forge).Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		_ = f.Close() //nolint:errcheck
+		return err
+	}
+	return f.Close()
+}
+
+// extractBinaries extracts the given binaries from a tar.gz or zip archive into
+// dir. Binaries are matched by file name, regardless of their directory within
+// the archive. Any other file is taken to be the binary itself, in which case
+// only one binary may be given.
+func extractBinaries(archive, dir string, bins []string) error {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+	want := make(map[string]bool, len(bins))
+	for _, bin := range bins {
+		want[bin] = true
+	}
+
+	name := strings.ToLower(filepath.Base(archive))
+	switch {
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		return extractTarGz(archive, dir, want)
+	case strings.HasSuffix(name, ".zip"):
+		return extractZip(archive, dir, want)
+	default:
+		if len(bins) != 1 {
+			return fmt.Errorf("asset is not an archive but %d binaries are expected", len(bins))
+		}
+		f, err := os.Open(archive) //nolint:gosec
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return writeBinary(filepath.Join(dir, bins[0]), f)
+	}
+}
+
+func extractTarGz(archive, dir string, want map[string]bool) error {
+	f, err := os.Open(archive) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Base(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || !want[name] {
+			continue
+		}
+		if err := writeBinary(filepath.Join(dir, name), tr); err != nil {
+			return err
+		}
+		delete(want, name)
+	}
+	return missingBinaries(want)
+}
+
+func extractZip(archive, dir string, want map[string]bool) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, zf := range zr.File {
+		name := path.Base(zf.Name)
+		if !zf.Mode().IsRegular() || !want[name] {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		err = writeBinary(filepath.Join(dir, name), rc)
+		_ = rc.Close() //nolint:errcheck
+		if err != nil {
+			return err
+		}
+		delete(want, name)
+	}
+	return missingBinaries(want)
+}
+
+func missingBinaries(want map[string]bool) error {
+	if len(want) == 0 {
+		return nil
+	}
+	var names []string
+	for name := range want {
+		names = append(names, name)
+	}
+	return fmt.Errorf("binaries not found in archive: %v", names)
+}
+
+func writeBinary(dst string, r io.Reader) error {
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0750)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close() //nolint:errcheck
+		return err
+	}
+	return f.Close()
+}
+
+// installBinary copies src to a temporary file next to dst, and renames it
+// over dst.
+func installBinary(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return err
+	}
+	in, err := os.Open(src) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp")
+	if err := writeBinary(tmp, in); err != nil {
+		_ = os.Remove(tmp) //nolint:errcheck
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		_ = os.Remove(tmp) //nolint:errcheck
+		return err
+	}
+	return nil
+}
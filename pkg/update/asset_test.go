@@ -0,0 +1,238 @@
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeTarGz(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0755,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func makeZip(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// withChecksums adds a checksum manifest of the given name to assets.
+func withChecksums(name string, assets map[string][]byte) map[string][]byte {
+	var manifest bytes.Buffer
+	for assetName, content := range assets {
+		fmt.Fprintf(&manifest, "%x  %s\n", sha256.Sum256(content), assetName)
+	}
+	assets[name] = manifest.Bytes()
+	return assets
+}
+
+// withSigs adds the signatures of the given asset to assets.
+func withSigs(t *testing.T, name string, assets map[string][]byte, keys ...cipher.SecKey) map[string][]byte {
+	assets[name+".sig"] = signData(t, assets[name], keys...)
+	return assets
+}
+
+// prepareGithub serves the given assets as the assets of release v1.0 of
+// "org/repo".
+func prepareGithub(t *testing.T, assets map[string][]byte) *httptest.Server {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+
+	release := GitReleaseBody{TagName: "v1.0", PubAt: "2019-03-20T10:00:00Z"}
+	for name, content := range assets {
+		content := content
+		release.Assets = append(release.Assets, GitReleaseAsset{
+			Name:        name,
+			Size:        int64(len(content)),
+			DownloadURL: srv.URL + "/download/" + name,
+		})
+		mux.HandleFunc("/download/"+name, func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write(content) //nolint:errcheck
+		})
+	}
+	serveRelease := func(w http.ResponseWriter, _ *http.Request) {
+		require.NoError(t, json.NewEncoder(w).Encode(release))
+	}
+	mux.HandleFunc("/repos/org/repo/releases/latest", serveRelease)
+	mux.HandleFunc("/repos/org/repo/releases/tags/v1.0", serveRelease)
+	return srv
+}
+
+func TestGithubAssetUpdater_Update(t *testing.T) {
+	host := fmt.Sprintf("%s-%s", runtime.GOOS, runtime.GOARCH)
+	mismatched := withChecksums("checksums.txt", map[string][]byte{
+		"repo-v1.0-" + host + ".zip": makeZip(t, map[string]string{"run": "run", "cli": "cli"}),
+	})
+	mismatched["repo-v1.0-"+host+".zip"] = makeZip(t, map[string]string{"run": "evil", "cli": "evil"})
+	pk, sk := cipher.GenerateKeyPair()
+	_, otherSK := cipher.GenerateKeyPair()
+
+	cases := []struct {
+		name         string
+		assets       map[string][]byte
+		version      string
+		trust        TrustConfig
+		wantErr      bool
+		wantChecksum *ChecksumError
+	}{
+		{
+			name: "tar.gz",
+			assets: withChecksums("repo_1.0_checksums.txt", map[string][]byte{
+				"repo-v1.0-" + host + ".tar.gz": makeTarGz(t, map[string]string{"repo/run": "run", "repo/cli": "cli", "README.md": "readme"}),
+				"repo-v1.0-plan9-mips.tar.gz":   makeTarGz(t, map[string]string{"run": "wrong", "cli": "wrong"}),
+			}),
+			version: "v1.0",
+		},
+		{
+			name: "zip",
+			assets: withChecksums("SHA256SUMS", map[string][]byte{
+				"repo-v1.0-" + host + ".zip": makeZip(t, map[string]string{"run": "run", "bin/cli": "cli"}),
+			}),
+		},
+		{
+			name: "missing manifest",
+			assets: map[string][]byte{
+				"repo-v1.0-" + host + ".zip": makeZip(t, map[string]string{"run": "run", "cli": "cli"}),
+			},
+			wantErr:      true,
+			wantChecksum: &ChecksumError{Artifact: "repo-v1.0-" + host + ".zip"},
+		},
+		{
+			name:         "mismatched checksum",
+			assets:       mismatched,
+			wantErr:      true,
+			wantChecksum: &ChecksumError{Artifact: "repo-v1.0-" + host + ".zip", Manifest: "checksums.txt"},
+		},
+		{
+			name: "signed checksums",
+			assets: withSigs(t, "checksums.txt", withChecksums("checksums.txt", map[string][]byte{
+				"repo-v1.0-" + host + ".zip": makeZip(t, map[string]string{"run": "run", "cli": "cli"}),
+			}), sk),
+			trust: TrustConfig{PubKeys: []string{pk.Hex()}},
+		},
+		{
+			name: "untrusted signature",
+			assets: withSigs(t, "checksums.txt", withChecksums("checksums.txt", map[string][]byte{
+				"repo-v1.0-" + host + ".zip": makeZip(t, map[string]string{"run": "run", "cli": "cli"}),
+			}), otherSK),
+			trust:   TrustConfig{PubKeys: []string{pk.Hex()}},
+			wantErr: true,
+		},
+		{
+			name: "missing signature",
+			assets: withChecksums("checksums.txt", map[string][]byte{
+				"repo-v1.0-" + host + ".zip": makeZip(t, map[string]string{"run": "run", "cli": "cli"}),
+			}),
+			trust:   TrustConfig{PubKeys: []string{pk.Hex()}},
+			wantErr: true,
+		},
+		{
+			name: "missing binary",
+			assets: withChecksums("checksums.txt", map[string][]byte{
+				"repo-v1.0-" + host + ".zip": makeZip(t, map[string]string{"run": "run"}),
+			}),
+			version: "v1.0",
+			wantErr: true,
+		},
+		{
+			name: "no matching asset",
+			assets: map[string][]byte{
+				"repo-v1.0-plan9-mips.zip": makeZip(t, map[string]string{"run": "run", "cli": "cli"}),
+			},
+			version: "v1.0",
+			wantErr: true,
+		},
+		{
+			name:    "unknown release",
+			version: "v2.0",
+			wantErr: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gh := prepareGithub(t, tc.assets)
+			defer gh.Close()
+
+			binDir, err := ioutil.TempDir(os.TempDir(), "bin")
+			require.NoError(t, err)
+			defer func() {
+				require.NoError(t, os.RemoveAll(binDir))
+			}()
+
+			c := ServiceConfig{
+				Repo:        "github.com/org/repo",
+				MainProcess: "run",
+				BinDir:      binDir,
+				Trust:       tc.trust,
+				Updater: UpdaterConfig{
+					Type:     GithubReleaseAssetUpdaterType,
+					BaseURL:  gh.URL,
+					Binaries: []string{"run", "cli"},
+				},
+			}
+			ok, err := NewUpdater("my-service", c, new(ServiceDefaultsConfig)).Update(context.TODO(), tc.version)
+			if tc.wantErr {
+				assert.Error(t, err)
+				assert.False(t, ok)
+				if tc.wantChecksum != nil {
+					cErr, isChecksumErr := err.(*ChecksumError)
+					require.True(t, isChecksumErr, err)
+					assert.Equal(t, tc.wantChecksum.Artifact, cErr.Artifact)
+					assert.Equal(t, tc.wantChecksum.Manifest, cErr.Manifest)
+					if cErr.Expected != "" {
+						assert.NotEqual(t, cErr.Expected, cErr.Actual)
+					}
+				}
+				_, err := os.Stat(filepath.Join(binDir, "run"))
+				assert.True(t, os.IsNotExist(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, ok)
+
+			for _, bin := range []string{"run", "cli"} {
+				raw, err := ioutil.ReadFile(filepath.Join(binDir, bin))
+				require.NoError(t, err)
+				assert.Equal(t, bin, string(raw))
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package update
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+)
+
+// Release channels which are defined by default.
+const (
+	StableChannel  = "stable"
+	BetaChannel    = "beta"
+	NightlyChannel = "nightly"
+)
+
+// ErrChannelNotFound occurs when a release channel is not defined for a service.
+var ErrChannelNotFound = errors.New("channel of given name is not found")
+
+// ErrChannelsNotSupported occurs when switching the release channel of a
+// service whose checker does not consider channels.
+var ErrChannelsNotSupported = errors.New("checker of service does not support release channels")
+
+// ChannelInfo is the release channel of a service.
+type ChannelInfo struct {
+	Channel  string   `json:"channel"`
+	Channels []string `json:"channels"` // Channels defined for the service.
+}
+
+// ChannelConfig defines which releases a release channel includes.
+type ChannelConfig struct {
+	Prerelease bool   `yaml:"prerelease,omitempty"` // Whether pre-releases are included.
+	Tags       string `yaml:"tags,omitempty"`       // Pattern of the tags of included releases (all tags if unspecified).
+}
+
+// defaultChannels are the channels which are defined for all services.
+var defaultChannels = map[string]ChannelConfig{
+	StableChannel:  {},
+	BetaChannel:    {Prerelease: true},
+	NightlyChannel: {Prerelease: true, Tags: "*nightly*"},
+}
+
+// includes returns whether the channel includes the given release.
+func (ch ChannelConfig) includes(release GitReleaseBody) bool {
+	if release.Draft || (release.Prerelease && !ch.Prerelease) {
+		return false
+	}
+	if ch.Tags == "" {
+		return true
+	}
+	ok, err := path.Match(ch.Tags, release.TagName)
+	return err == nil && ok
+}
+
+// latestOnly returns whether the channel only includes the latest release (as
+// reported by github).
+func (ch ChannelConfig) latestOnly() bool {
+	return !ch.Prerelease && ch.Tags == ""
+}
+
+// channel returns the definition of the given channel of the service.
+func (c ServiceConfig) channel(name string) (ChannelConfig, bool) {
+	if ch, ok := c.Channels[name]; ok {
+		return ch, true
+	}
+	ch, ok := defaultChannels[name]
+	return ch, ok
+}
+
+// channelNames lists the names of the channels defined for the service.
+func (c ServiceConfig) channelNames() []string {
+	var names []string
+	for name := range defaultChannels {
+		names = append(names, name)
+	}
+	for name := range c.Channels {
+		if _, ok := defaultChannels[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Checks for errors and fills unspecified fields with default values.
+func processChannelConfig(sc *ServiceConfig, d *ServiceDefaultsConfig) error {
+	for name, ch := range d.Channels {
+		if _, ok := sc.Channels[name]; ok {
+			continue
+		}
+		if sc.Channels == nil {
+			sc.Channels = make(map[string]ChannelConfig)
+		}
+		sc.Channels[name] = ch
+	}
+	for name, ch := range sc.Channels {
+		if _, err := path.Match(ch.Tags, ""); err != nil {
+			return fmt.Errorf("channels.%s.tags is invalid: %s", name, err)
+		}
+	}
+	if sc.Channel == "" {
+		sc.Channel = d.Channel
+	}
+	if sc.Channel == "" {
+		sc.Channel = StableChannel
+	}
+	if _, ok := sc.channel(sc.Channel); !ok {
+		return fmt.Errorf("channel '%s' is not defined when expecting: %v", sc.Channel, sc.channelNames())
+	}
+	return nil
+}
+
+// supportsChannels returns whether the checker considers release channels.
+// Composite checkers do if any of their child checkers do.
+func supportsChannels(c CheckerConfig) bool {
+	switch c.Type {
+	case GithubReleaseCheckerType:
+		return true
+	case CompositeCheckerType:
+		for _, child := range c.Checkers {
+			if supportsChannels(child) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package update
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessChannelConfig(t *testing.T) {
+	d := &ServiceDefaultsConfig{
+		Channel:  BetaChannel,
+		Channels: map[string]ChannelConfig{"lts": {Tags: "*-lts"}},
+	}
+	sc := &ServiceConfig{Channels: map[string]ChannelConfig{"rc": {Prerelease: true, Tags: "*-rc*"}}}
+	require.NoError(t, processChannelConfig(sc, d))
+	assert.Equal(t, BetaChannel, sc.Channel)
+	assert.Equal(t, []string{BetaChannel, "lts", NightlyChannel, "rc", StableChannel}, sc.channelNames())
+
+	assert.Error(t, processChannelConfig(&ServiceConfig{Channel: "unknown"}, d))
+	assert.Error(t, processChannelConfig(&ServiceConfig{Channels: map[string]ChannelConfig{"bad": {Tags: "["}}}, d))
+}
+
+func TestManager_SetChannel(t *testing.T) {
+	db, rmDB := prepareDB(t)
+	defer rmDB()
+
+	conf := NewConfig(os.TempDir(), os.TempDir())
+	conf.Services.Services["srv"] = &ServiceConfig{
+		CheckInterval: -1,
+		Channel:       StableChannel,
+		Repo:          "github.com/skycoin/skywire",
+		Checker:       CheckerConfig{Type: GithubReleaseCheckerType},
+		Updater:       UpdaterConfig{Type: ScriptUpdaterType},
+	}
+	conf.Services.Services["script"] = &ServiceConfig{
+		CheckInterval: -1,
+		Checker:       CheckerConfig{Type: ScriptCheckerType},
+		Updater:       UpdaterConfig{Type: ScriptUpdaterType},
+	}
+	m := NewManager(db, conf)
+	defer func() {
+		require.NoError(t, m.Close())
+	}()
+
+	info, err := m.Channel("srv")
+	require.NoError(t, err)
+	assert.Equal(t, StableChannel, info.Channel)
+
+	require.NoError(t, m.SetChannel("srv", BetaChannel))
+	info, err = m.Channel("srv")
+	require.NoError(t, err)
+	assert.Equal(t, BetaChannel, info.Channel)
+	assert.Equal(t, BetaChannel, db.ServiceLastUpdate("srv").Channel)
+
+	assert.Equal(t, ErrChannelNotFound, m.SetChannel("srv", "unknown"))
+	assert.Equal(t, ErrServiceNotFound, m.SetChannel("unknown", BetaChannel))
+
+	// Checkers which do not consider channels cannot be switched.
+	assert.Equal(t, ErrChannelsNotSupported, m.SetChannel("script", BetaChannel))
+	assert.False(t, supportsChannels(CheckerConfig{Type: CompositeCheckerType, Checkers: []CheckerConfig{{Type: GitRefCheckerType}}}))
+	assert.True(t, supportsChannels(CheckerConfig{Type: CompositeCheckerType, Checkers: []CheckerConfig{{Type: GitRefCheckerType}, {Type: GithubReleaseCheckerType}}}))
+}
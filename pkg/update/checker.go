@@ -2,13 +2,7 @@ package update
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"net/http"
-	"os"
 	"os/exec"
-	"path"
-	"strings"
 	"time"
 
 	"github.com/skycoin/skycoin/src/util/logging"
@@ -25,20 +19,46 @@ const (
 
 	// ScriptCheckerType type.
 	ScriptCheckerType = CheckerType("script")
+
+	// HTTPManifestCheckerType type.
+	HTTPManifestCheckerType = CheckerType("http-manifest")
+
+	// GitRefCheckerType type.
+	GitRefCheckerType = CheckerType("git-ref")
+
+	// GoProxyCheckerType type.
+	GoProxyCheckerType = CheckerType("goproxy")
+
+	// OCIRegistryCheckerType type.
+	OCIRegistryCheckerType = CheckerType("oci-registry")
+
+	// CompositeCheckerType type.
+	CompositeCheckerType = CheckerType("composite")
 )
 
 var checkerTypes = []CheckerType{
 	GithubReleaseCheckerType,
 	ScriptCheckerType,
+	HTTPManifestCheckerType,
+	GitRefCheckerType,
+	GoProxyCheckerType,
+	OCIRegistryCheckerType,
+	CompositeCheckerType,
 }
 
 // Release is obtained from a check.
 type Release struct {
-	HasUpdate   bool            `json:"update_available"`
-	Version     string          `json:"release_version"`
-	Timestamp   time.Time       `json:"release_timestamp"`
-	CheckerType CheckerType     `json:"checker_type"`
-	GitRelease  *GitReleaseBody `json:"git_release,omitempty"`
+	HasUpdate      bool            `json:"update_available"`
+	Version        string          `json:"release_version"`
+	Timestamp      time.Time       `json:"release_timestamp"`
+	CheckerType    CheckerType     `json:"checker_type"`
+	Channel        string          `json:"channel,omitempty"`
+	CurrentVersion string          `json:"current_version,omitempty"` // Installed version of the service.
+	Notes          string          `json:"notes,omitempty"`           // Release notes (if known).
+	Artifacts      []Artifact      `json:"artifacts,omitempty"`       // Downloadable files of the release (if known).
+	Digest         string          `json:"digest,omitempty"`          // Digest of the release's image manifest (oci-registry checker).
+	Sources        []*Release      `json:"sources,omitempty"`         // Results of the child checkers (composite checker).
+	GitRelease     *GitReleaseBody `json:"git_release,omitempty"`
 }
 
 // Checker represents a Checker implementation.
@@ -53,6 +73,16 @@ func NewChecker(db store.Store, srvName string, c ServiceConfig, d *ServiceDefau
 		return NewGithubReleaseChecker(db, srvName, c)
 	case ScriptCheckerType:
 		return NewScriptChecker(srvName, c, d)
+	case HTTPManifestCheckerType:
+		return NewManifestChecker(db, srvName, c)
+	case GitRefCheckerType:
+		return NewGitRefChecker(db, srvName, c)
+	case GoProxyCheckerType:
+		return NewGoProxyChecker(db, srvName, c)
+	case OCIRegistryCheckerType:
+		return NewOCIRegistryChecker(db, srvName, c)
+	case CompositeCheckerType:
+		return NewCompositeChecker(db, srvName, c, d)
 	default:
 		log.Fatalf("invalid checker type '%s' at 'services[%s].checker.type' when expecting: %v",
 			c.Checker.Type, srvName, checkerTypes)
@@ -76,67 +106,145 @@ func NewScriptChecker(srvName string, c ServiceConfig, d *ServiceDefaultsConfig)
 	}
 }
 
-// Check checks for updates.
+// Check checks for updates. The release is described by the script's
+// structured result, if it writes one.
 func (sc *ScriptChecker) Check(ctx context.Context) (*Release, error) {
 	check := sc.c.Checker
 	cmd := exec.Command(check.Interpreter, append([]string{check.Script}, check.Args...)...) //nolint:gosec
 	cmd.Env = CheckerEnvs(sc.d, &sc.c)
-	hasUpdate, err := ExecuteScript(ctx, sc.log, cmd)
+	hasUpdate, result, err := ExecuteScriptResult(ctx, sc.log, cmd)
 	if err != nil {
 		return nil, err
 	}
-	return &Release{
+	release := &Release{
 		HasUpdate:   hasUpdate,
 		Timestamp:   time.Now(),
 		CheckerType: ScriptCheckerType,
-	}, nil
+	}
+	if result != nil {
+		release.Version = result.Version
+		if !result.Timestamp.IsZero() {
+			release.Timestamp = result.Timestamp
+		}
+		release.Notes = result.Notes
+		release.Artifacts = result.Artifacts
+	}
+	return release, nil
 }
 
-// GithubReleaseChecker checks for available updates via the github API.
+// GithubReleaseChecker checks for available updates via the releases API of
+// the service's forge (github, or another forge). Releases are compared to the
+// installed version as semantic versions.
 type GithubReleaseChecker struct {
-	srvName string
-	c       ServiceConfig
-	db      store.Store
-	log     *logging.Logger
+	srvName    string
+	c          ServiceConfig
+	constraint *Constraint // Restricts the releases to update to (if not nil).
+	forge      Forge
+	db         store.Store
+	log        *logging.Logger
 }
 
 // NewGithubReleaseChecker creates a new GithubReleaseChecker.
 func NewGithubReleaseChecker(db store.Store, srvName string, c ServiceConfig) *GithubReleaseChecker {
-	return &GithubReleaseChecker{
+	gc := &GithubReleaseChecker{
 		srvName: srvName,
 		c:       c,
 		db:      db,
 		log:     logging.MustGetLogger("release-checker." + srvName),
 	}
+	gc.forge = newServiceForge(gc.log, c, c.Checker.BaseURL)
+	if c.Constraint != "" {
+		constraint, err := ParseConstraint(c.Constraint)
+		if err != nil {
+			gc.log.WithError(err).Fatal("Invalid version constraint.")
+		}
+		gc.constraint = constraint
+	}
+	return gc
 }
 
-// Check checks for updates.
+// Check checks for updates. The newest release of the service's release
+// channel is checked (which satisfies the service's version constraint if it
+// has one). An update is available if the release's version is higher than the
+// installed version.
 func (gc *GithubReleaseChecker) Check(ctx context.Context) (*Release, error) {
-	body, err := gc.fetchFromGit(ctx)
+	last := gc.db.ServiceLastUpdate(gc.srvName)
+	chName, ch := gc.channel(last)
+	var body *GitReleaseBody
+	var err error
+	if gc.constraint == nil && ch.latestOnly() {
+		body, err = gc.forge.LatestRelease(ctx)
+	} else {
+		body, err = gc.fetchNewest(ctx, last, ch)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if body == nil {
+		progress(ctx, gc.log, "No release of channel '%s' satisfies constraint '%s'.", chName, gc.c.Constraint)
+		return &Release{CheckerType: GithubReleaseCheckerType, Channel: chName}, nil
+	}
 	pubAt, err := body.ParsePubAt()
 	if err != nil {
 		return nil, err
 	}
-	last := gc.db.ServiceLastUpdate(gc.srvName)
-	hasUpdate := last.IsEmpty() || last.Timestamp < pubAt.UnixNano()
+	hasUpdate := isNewer(gc.log, body.TagName, pubAt, last)
+	if hasUpdate && last.IsRolledBack(body.TagName) {
+		gc.log.Infof("Release '%s' is not offered as it was rolled back from.", body.TagName)
+		hasUpdate = false
+	}
 	return &Release{
 		HasUpdate:   hasUpdate,
 		Version:     body.TagName,
 		Timestamp:   pubAt,
 		CheckerType: GithubReleaseCheckerType,
+		Channel:     chName,
 		GitRelease:  body,
 	}, nil
 }
 
+// channel returns the release channel of the service, which is the channel
+// switched to via the API (if any), or the configured channel.
+func (gc *GithubReleaseChecker) channel(last store.Update) (string, ChannelConfig) {
+	for _, name := range []string{last.Channel, gc.c.Channel} {
+		if ch, ok := gc.c.channel(name); ok {
+			return name, ch
+		}
+	}
+	return StableChannel, defaultChannels[StableChannel]
+}
+
+// isNewer returns whether the release of given tag is newer than the installed
+// version. If either is not a semantic version, the release is newer if it was
+// published after the last update (or, if its publish time is unknown, if its
+// tag differs from the installed version).
+func isNewer(l *logging.Logger, tag string, pubAt time.Time, last store.Update) bool {
+	if last.Installed() == "" {
+		return true
+	}
+	release, rErr := ParseVersion(tag)
+	installed, iErr := ParseVersion(last.Installed())
+	if rErr != nil || iErr != nil {
+		if pubAt.IsZero() {
+			return tag != last.Installed()
+		}
+		l.Infof("Comparing publish time of release '%s' as it or installed version '%s' is not a semantic version.",
+			tag, last.Installed())
+		return last.Timestamp < pubAt.UnixNano()
+	}
+	return release.Compare(installed) > 0
+}
+
 // GitReleaseBody is the response body of the github API call.
 type GitReleaseBody struct {
-	URL     string `json:"url,omitempty"`
-	TagName string `json:"tag_name,omitempty"`
-	PubAt   string `json:"published_at,omitempty"`
-	Body    string `json:"body,omitempty"`
+	URL        string `json:"url,omitempty"`
+	TagName    string `json:"tag_name,omitempty"`
+	PubAt      string `json:"published_at,omitempty"`
+	Body       string `json:"body,omitempty"`
+	Draft      bool   `json:"draft,omitempty"`
+	Prerelease bool   `json:"prerelease,omitempty"`
+
+	Assets []GitReleaseAsset `json:"assets,omitempty"`
 }
 
 // ParsePubAt parses the published_at field.
@@ -144,42 +252,46 @@ func (grb GitReleaseBody) ParsePubAt() (time.Time, error) {
 	return time.Parse(time.RFC3339, grb.PubAt)
 }
 
-func (gc *GithubReleaseChecker) fetchFromGit(ctx context.Context) (*GitReleaseBody, error) {
-	repo := strings.TrimPrefix(gc.c.Repo, "github.com")
-	url := "https://" + path.Join("api.github.com/repos/", repo, "/releases/latest")
-	gc.log.Infoln("Request URL:", url)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if ok := addBasicAuth(req); ok {
-		gc.log.Infof("Added basic auth headers.")
-	}
-	if err != nil {
-		gc.log.WithError(err).Fatalln("failed to formulate 'fetchFromGit' request")
-		return nil, err
-	}
-	req.Header.Add("Accept", "application/vnd.github.v3+json")
-	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+// fetchNewest fetches the newest release which is included in the channel,
+// satisfies the constraint (if any) and was not rolled back from. It returns
+// nil if there is no such release.
+func (gc *GithubReleaseChecker) fetchNewest(ctx context.Context, last store.Update, ch ChannelConfig) (*GitReleaseBody, error) {
+	bodies, err := gc.forge.Releases(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	var body GitReleaseBody
-	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
-		gc.log.WithError(err).Fatalln("unrecognised json body")
-		return nil, err
+	var newest *GitReleaseBody
+	for i, body := range bodies {
+		if !ch.includes(body) || last.IsRolledBack(body.TagName) {
+			continue
+		}
+		if gc.constraint != nil {
+			v, err := ParseVersion(body.TagName)
+			if err != nil {
+				gc.log.Debugf("Ignoring release '%s': %s", body.TagName, err)
+				continue
+			}
+			if !gc.constraint.Check(v) {
+				continue
+			}
+		}
+		if newest == nil || newerRelease(body, *newest) {
+			newest = &bodies[i]
+		}
 	}
-
-	jStr, _ := json.MarshalIndent(body, "", "    ") //nolint:errcheck
-	gc.log.Infoln(fmt.Sprintf("Response (%s):", resp.Status), string(jStr))
-	return &body, nil
+	return newest, nil
 }
 
-func addBasicAuth(req *http.Request) bool {
-	usr, usrOK := os.LookupEnv(EnvGithubUsername)
-	pac, pacOK := os.LookupEnv(EnvGithubAccessToken)
-	if usrOK && pacOK {
-		req.SetBasicAuth(usr, pac)
-		return true
+// newerRelease returns whether release a is newer than release b. Releases are
+// compared as semantic versions, or by publish time if either tag is not a
+// semantic version (such as the tags of nightly builds).
+func newerRelease(a, b GitReleaseBody) bool {
+	av, aErr := ParseVersion(a.TagName)
+	bv, bErr := ParseVersion(b.TagName)
+	if aErr == nil && bErr == nil {
+		return av.Compare(bv) > 0
 	}
-	return false
+	aPub, _ := a.ParsePubAt() //nolint:errcheck
+	bPub, _ := b.ParsePubAt() //nolint:errcheck
+	return aPub.After(bPub)
 }
@@ -2,10 +2,15 @@ package update
 
 import (
 	"context"
+	"encoding/json"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/skycoin/skywire-updater/pkg/store"
@@ -56,3 +61,81 @@ func TestScriptChecker_Check(t *testing.T) {
 	require.Equal(t, c.Checker.Type, r.CheckerType)
 	t.Log(r)
 }
+
+// prepareGithubReleases serves the given releases of "org/repo", newest first.
+// The first release which is neither a draft nor a pre-release is the latest.
+func prepareGithubReleases(t *testing.T, releases []GitReleaseBody) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/org/repo/releases", func(w http.ResponseWriter, _ *http.Request) {
+		require.NoError(t, json.NewEncoder(w).Encode(releases))
+	})
+	mux.HandleFunc("/repos/org/repo/releases/latest", func(w http.ResponseWriter, _ *http.Request) {
+		for _, r := range releases {
+			if !r.Draft && !r.Prerelease {
+				require.NoError(t, json.NewEncoder(w).Encode(r))
+				return
+			}
+		}
+		http.NotFound(w, nil)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestGithubReleaseChecker_Check(t *testing.T) {
+	gh := prepareGithubReleases(t, []GitReleaseBody{
+		{TagName: "v1.0.0-rc.1", PubAt: "2019-05-01T10:00:00Z", Prerelease: true},
+		{TagName: "v0.3.0", PubAt: "2019-04-01T10:00:00Z"},
+		{TagName: "v0.2.10", PubAt: "2019-04-02T10:00:00Z"}, // A re-published patch of an old version.
+		{TagName: "v0.2.2", PubAt: "2019-03-01T10:00:00Z"},
+		{TagName: "nightly-20190306", PubAt: "2019-03-06T10:00:00Z", Prerelease: true},
+		{TagName: "nightly-20190305", PubAt: "2019-03-05T10:00:00Z", Prerelease: true},
+		{TagName: "v2.0.0", PubAt: "2019-06-01T10:00:00Z", Draft: true},
+	})
+	defer gh.Close()
+	published := time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name        string
+		installed   store.Update
+		constraint  string
+		channel     string
+		wantVersion string
+		wantUpdate  bool
+	}{
+		{"nothing installed", store.Update{}, "", "", "v0.3.0", true},
+		{"older installed", store.Update{Tag: "v0.2.2", Timestamp: published.Add(time.Hour).UnixNano()}, "", "", "v0.3.0", true},
+		{"same installed", store.Update{Tag: "v0.3.0", Timestamp: published.Add(-time.Hour).UnixNano()}, "", "", "v0.3.0", false},
+		{"newer installed", store.Update{Tag: "v0.4.0"}, "", "", "v0.3.0", false},
+		{"not semver installed", store.Update{Tag: "master", Timestamp: published.Add(-time.Hour).UnixNano()}, "", "", "v0.3.0", true},
+		{"constraint", store.Update{Tag: "v0.2.2"}, "~0.2", "", "v0.2.10", true},
+		{"constraint satisfied by installed", store.Update{Tag: "v0.2.10"}, "<0.3.0", "", "v0.2.10", false},
+		{"rolled back", store.Update{Tag: "v0.2.2", RolledBack: []string{"v0.2.10"}}, "~0.2", "", "v0.2.2", false},
+		{"constraint unsatisfied", store.Update{Tag: "v0.2.2"}, ">=2.0", "", "", false},
+		{"beta channel", store.Update{Tag: "v0.3.0"}, "", BetaChannel, "v1.0.0-rc.1", true},
+		{"beta channel with constraint", store.Update{Tag: "v0.2.2"}, "<0.4", BetaChannel, "v0.3.0", true},
+		{"nightly channel", store.Update{Tag: "nightly-20190305", Timestamp: time.Date(2019, 3, 5, 12, 0, 0, 0, time.UTC).UnixNano()}, "", NightlyChannel, "nightly-20190306", true},
+		{"switched channel", store.Update{Tag: "v0.3.0", Channel: BetaChannel}, "", StableChannel, "v1.0.0-rc.1", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, rmDB := prepareDB(t)
+			defer rmDB()
+			if tc.installed.Tag != "" {
+				require.NoError(t, db.SetServiceLastUpdate("srv", tc.installed))
+			}
+			c := ServiceConfig{
+				Repo:       "github.com/org/repo",
+				Constraint: tc.constraint,
+				Channel:    tc.channel,
+				Checker:    CheckerConfig{Type: GithubReleaseCheckerType, BaseURL: gh.URL},
+			}
+			r, err := NewChecker(db, "srv", c, new(ServiceDefaultsConfig)).Check(context.TODO())
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantVersion, r.Version)
+			assert.Equal(t, tc.wantUpdate, r.HasUpdate)
+			if tc.wantVersion != "" {
+				assert.NotEmpty(t, r.Channel)
+			}
+		})
+	}
+}
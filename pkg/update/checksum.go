@@ -0,0 +1,101 @@
+package update
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// DefaultChecksumsPatterns are the names of checksum manifest assets which are
+// looked for (in order) if no pattern is configured. These match the manifests
+// produced by goreleaser and sha256sum.
+var DefaultChecksumsPatterns = []string{"*checksums.txt", "SHA256SUMS"}
+
+// ChecksumError occurs when a downloaded artifact cannot be verified against
+// the SHA-256 checksum manifest of its release.
+type ChecksumError struct {
+	Artifact string
+	Manifest string // Name of the manifest (empty if the release has none).
+	Expected string // Empty if the artifact is not listed in the manifest.
+	Actual   string
+}
+
+// Error implements error.
+func (e *ChecksumError) Error() string {
+	switch {
+	case e.Manifest == "":
+		return fmt.Sprintf("cannot verify '%s': release has no checksum manifest", e.Artifact)
+	case e.Expected == "":
+		return fmt.Sprintf("cannot verify '%s': not listed in checksum manifest '%s'", e.Artifact, e.Manifest)
+	default:
+		return fmt.Sprintf("checksum mismatch for '%s': expected sha256 %s (from '%s'), got %s",
+			e.Artifact, e.Expected, e.Manifest, e.Actual)
+	}
+}
+
+// checksums are the SHA-256 checksums of a manifest, keyed by file name.
+type checksums struct {
+	manifest string
+	path     string // Local path of the downloaded manifest.
+	sums     map[string]string
+}
+
+// parseChecksums parses a manifest of lines of format '<hex sha256>  <name>'.
+func parseChecksums(manifest string, r io.Reader) (*checksums, error) {
+	c := &checksums{manifest: manifest, sums: make(map[string]string)}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid line in checksum manifest '%s': %q", manifest, line)
+		}
+		sum, name := strings.ToLower(fields[0]), strings.TrimPrefix(fields[1], "*") // '*' marks binary mode.
+		if raw, err := hex.DecodeString(sum); err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("invalid sha256 checksum in manifest '%s': %q", manifest, fields[0])
+		}
+		c.sums[path.Base(name)] = sum
+	}
+	return c, sc.Err()
+}
+
+// verify verifies the file at filePath, which is listed under the given name.
+func (c *checksums) verify(name, filePath string) error {
+	if c == nil {
+		return &ChecksumError{Artifact: name}
+	}
+	expected, ok := c.sums[name]
+	if !ok {
+		return &ChecksumError{Artifact: name, Manifest: c.manifest}
+	}
+	actual, err := sha256File(filePath)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return &ChecksumError{Artifact: name, Manifest: c.manifest, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+func sha256File(filePath string) (string, error) {
+	f, err := os.Open(filePath) //nolint:gosec
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,23 @@
+package update
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChecksums(t *testing.T) {
+	const sum = "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+
+	c, err := parseChecksums("SHA256SUMS", strings.NewReader(
+		"# comment\n"+sum+"  a.tar.gz\n"+strings.ToUpper(sum)+" *dist/b.zip\n\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a.tar.gz": sum, "b.zip": sum}, c.sums)
+
+	_, err = parseChecksums("SHA256SUMS", strings.NewReader("abcd  a.tar.gz\n"))
+	assert.Error(t, err)
+	_, err = parseChecksums("SHA256SUMS", strings.NewReader(sum+"\n"))
+	assert.Error(t, err)
+}
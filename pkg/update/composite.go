@@ -0,0 +1,192 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+
+	"github.com/skycoin/skywire-updater/pkg/store"
+)
+
+// CheckerCombinator determines how a composite checker combines the results of
+// its child checkers.
+type CheckerCombinator string
+
+const (
+	// AllCombinator reports an update if all child checkers report one.
+	AllCombinator = CheckerCombinator("all")
+
+	// AnyCombinator reports an update if any child checker reports one.
+	AnyCombinator = CheckerCombinator("any")
+
+	// FirstSuccessCombinator reports the result of the first child checker
+	// which succeeds.
+	FirstSuccessCombinator = CheckerCombinator("first-success")
+
+	// QuorumCombinator reports an update if a quorum of child checkers report
+	// one.
+	QuorumCombinator = CheckerCombinator("quorum")
+)
+
+var checkerCombinators = []CheckerCombinator{
+	AllCombinator,
+	AnyCombinator,
+	FirstSuccessCombinator,
+	QuorumCombinator,
+}
+
+// CompositeChecker checks for available updates with several child checkers,
+// and combines their results.
+//
+// With the "all", "any" and "quorum" combinators, all child checkers are run,
+// and an update is reported if at least the required number of them (all, one
+// or the quorum) report one. The reported version is the newest which that
+// many checkers report (or a newer one), so that "all" reports the oldest of
+// the reported versions and "any" the newest. Checkers which fail count as not
+// reporting an update, but the check fails if too few of them succeed to reach
+// the required number.
+type CompositeChecker struct {
+	srvName  string
+	c        CheckerConfig
+	children []Checker
+	log      *logging.Logger
+}
+
+// NewCompositeChecker creates a new CompositeChecker and its child checkers,
+// and panics on failure.
+func NewCompositeChecker(db store.Store, srvName string, c ServiceConfig, d *ServiceDefaultsConfig) *CompositeChecker {
+	cc := c.Checker
+	setCompositeCheckerDefaults(&cc)
+	children := make([]Checker, len(cc.Checkers))
+	for i, child := range cc.Checkers {
+		c.Checker = child
+		children[i] = NewChecker(db, srvName, c, d)
+	}
+	return &CompositeChecker{
+		srvName:  srvName,
+		c:        cc,
+		children: children,
+		log:      logging.MustGetLogger("composite-checker." + srvName),
+	}
+}
+
+// Check checks for updates.
+func (cc *CompositeChecker) Check(ctx context.Context) (*Release, error) {
+	if cc.c.Combinator == FirstSuccessCombinator {
+		return cc.firstSuccess(ctx)
+	}
+	required := cc.required()
+	var releases []*Release
+	var errs []string
+	for i, child := range cc.children {
+		r, err := child.Check(ctx)
+		if err != nil {
+			errs = append(errs, cc.childError(i, err))
+			continue
+		}
+		releases = append(releases, r)
+	}
+	if len(releases) < required {
+		return nil, fmt.Errorf("%d of %d checkers failed: %s", len(errs), len(cc.children), strings.Join(errs, "; "))
+	}
+
+	var updates []*Release
+	for _, r := range releases {
+		if r.HasUpdate {
+			updates = append(updates, r)
+		}
+	}
+	sort.SliceStable(updates, func(i, j int) bool {
+		return newerTag(updates[i].Version, updates[j].Version)
+	})
+	progress(ctx, cc.log, "%d of %d checkers report an update (%d required).", len(updates), len(cc.children), required)
+
+	release := *releases[0]
+	release.HasUpdate = false
+	if len(updates) >= required {
+		release = *updates[required-1]
+	}
+	release.CheckerType = CompositeCheckerType
+	release.Sources = releases
+	return &release, nil
+}
+
+// firstSuccess reports the result of the first child checker which succeeds.
+func (cc *CompositeChecker) firstSuccess(ctx context.Context) (*Release, error) {
+	var errs []string
+	for i, child := range cc.children {
+		r, err := child.Check(ctx)
+		if err != nil {
+			errs = append(errs, cc.childError(i, err))
+			continue
+		}
+		release := *r
+		release.CheckerType = CompositeCheckerType
+		release.Sources = []*Release{r}
+		return &release, nil
+	}
+	return nil, fmt.Errorf("all checkers failed: %s", strings.Join(errs, "; "))
+}
+
+// required returns the number of child checkers which need to report an
+// update.
+func (cc *CompositeChecker) required() int {
+	switch cc.c.Combinator {
+	case AnyCombinator:
+		return 1
+	case QuorumCombinator:
+		return cc.c.Quorum
+	default:
+		return len(cc.children)
+	}
+}
+
+func (cc *CompositeChecker) childError(i int, err error) string {
+	cc.log.WithError(err).Warnf("Checker %d (%s) failed.", i, cc.c.Checkers[i].Type)
+	return fmt.Sprintf("checker %d (%s): %s", i, cc.c.Checkers[i].Type, err)
+}
+
+func setCompositeCheckerDefaults(cc *CheckerConfig) {
+	if cc.Combinator == "" {
+		cc.Combinator = AllCombinator
+	}
+	if cc.Combinator == QuorumCombinator && cc.Quorum == 0 {
+		cc.Quorum = len(cc.Checkers)/2 + 1
+	}
+}
+
+// Checks for errors and fills unspecified fields with default values. The
+// child checkers are processed as checkers of the service.
+func processCompositeCheckerConfig(sc *ServiceConfig, scriptsPath string, d *ServiceDefaultsConfig) error {
+	cc := &sc.Checker
+	if len(cc.Checkers) == 0 {
+		return errors.New("checker.checkers needs to be defined")
+	}
+	setCompositeCheckerDefaults(cc)
+	switch cc.Combinator {
+	case AllCombinator, AnyCombinator, FirstSuccessCombinator:
+	case QuorumCombinator:
+		if cc.Quorum < 1 || cc.Quorum > len(cc.Checkers) {
+			return fmt.Errorf("checker.quorum needs to be between 1 and the number of checkers (%d)", len(cc.Checkers))
+		}
+	default:
+		return fmt.Errorf("invalid checker.combinator '%s' when expecting: %v", cc.Combinator, checkerCombinators)
+	}
+	for i := range cc.Checkers {
+		child := *sc
+		child.Checker = cc.Checkers[i]
+		if child.Checker.Type == "" {
+			return fmt.Errorf("checker.checkers[%d].type needs to be defined", i)
+		}
+		if err := processCheckerConfig(&child, scriptsPath, d); err != nil {
+			return fmt.Errorf("checker.checkers[%d]: %s", i, err)
+		}
+		cc.Checkers[i] = child.Checker
+		sc.MainBranch = child.MainBranch // Defaulted by some checkers.
+	}
+	return nil
+}
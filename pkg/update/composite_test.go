@@ -0,0 +1,128 @@
+package update
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-updater/pkg/store"
+)
+
+func TestCompositeChecker_Check(t *testing.T) {
+	// Serves manifests of the version in the path, such as '/v0.3.0', and fails
+	// for '/fail'.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"version": "` + strings.TrimPrefix(r.URL.Path, "/") + `"}`)) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	db, rmDB := prepareDB(t)
+	defer rmDB()
+	require.NoError(t, db.SetServiceLastUpdate("srv", store.Update{Tag: "v0.2.0"}))
+
+	manifests := func(versions ...string) []CheckerConfig {
+		checkers := make([]CheckerConfig, len(versions))
+		for i, v := range versions {
+			checkers[i] = CheckerConfig{Type: HTTPManifestCheckerType, URL: srv.URL + "/" + v}
+		}
+		return checkers
+	}
+	cases := []struct {
+		name       string
+		combinator CheckerCombinator
+		quorum     int
+		versions   []string
+		wantErr    bool
+		wantUpdate bool
+		wantV      string
+	}{
+		{"all", AllCombinator, 0, []string{"v0.3.0", "v0.4.0"}, false, true, "v0.3.0"},
+		{"all without update", AllCombinator, 0, []string{"v0.3.0", "v0.2.0"}, false, false, "v0.3.0"},
+		{"all failing", AllCombinator, 0, []string{"v0.3.0", "fail"}, true, false, ""},
+		{"any", AnyCombinator, 0, []string{"v0.2.0", "v0.4.0", "v0.3.0"}, false, true, "v0.4.0"},
+		{"any failing", AnyCombinator, 0, []string{"fail", "v0.3.0"}, false, true, "v0.3.0"},
+		{"any without update", AnyCombinator, 0, []string{"v0.2.0", "v0.1.0"}, false, false, "v0.2.0"},
+		{"quorum", QuorumCombinator, 2, []string{"v0.5.0", "v0.4.0", "v0.3.0"}, false, true, "v0.4.0"},
+		{"quorum failing", QuorumCombinator, 2, []string{"v0.2.0", "v0.3.0", "fail"}, false, false, "v0.2.0"},
+		{"quorum unreachable", QuorumCombinator, 2, []string{"fail", "v0.3.0", "fail"}, true, false, ""},
+		{"first-success", FirstSuccessCombinator, 0, []string{"fail", "v0.3.0", "v0.4.0"}, false, true, "v0.3.0"},
+		{"first-success failing", FirstSuccessCombinator, 0, []string{"fail", "fail"}, true, false, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := ServiceConfig{Checker: CheckerConfig{
+				Type:       CompositeCheckerType,
+				Checkers:   manifests(tc.versions...),
+				Combinator: tc.combinator,
+				Quorum:     tc.quorum,
+			}}
+			r, err := NewChecker(db, "srv", c, &ServiceDefaultsConfig{}).Check(context.Background())
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantUpdate, r.HasUpdate)
+			assert.Equal(t, tc.wantV, r.Version)
+			assert.Equal(t, CompositeCheckerType, r.CheckerType)
+			assert.NotEmpty(t, r.Sources)
+		})
+	}
+
+	// Composite checkers can be nested.
+	c := ServiceConfig{Checker: CheckerConfig{
+		Type:       CompositeCheckerType,
+		Combinator: AllCombinator,
+		Checkers: []CheckerConfig{
+			{Type: HTTPManifestCheckerType, URL: srv.URL + "/v0.4.0"},
+			{Type: CompositeCheckerType, Combinator: AnyCombinator, Checkers: manifests("fail", "v0.3.0")},
+		},
+	}}
+	r, err := NewChecker(db, "srv", c, &ServiceDefaultsConfig{}).Check(context.Background())
+	require.NoError(t, err)
+	assert.True(t, r.HasUpdate)
+	assert.Equal(t, "v0.3.0", r.Version)
+	require.Len(t, r.Sources, 2)
+	assert.Len(t, r.Sources[1].Sources, 1)
+}
+
+func TestProcessCompositeCheckerConfig(t *testing.T) {
+	d := &ServiceDefaultsConfig{MainBranch: "master"}
+	sc := ServiceConfig{
+		Repo: "github.com/skycoin/skywire",
+		Checker: CheckerConfig{
+			Type:       CompositeCheckerType,
+			Combinator: QuorumCombinator,
+			Checkers: []CheckerConfig{
+				{Type: GithubReleaseCheckerType},
+				{Type: GoProxyCheckerType, GoProxy: "https://goproxy.example.com"},
+				{Type: GitRefCheckerType},
+			},
+		},
+	}
+	require.NoError(t, processCompositeCheckerConfig(&sc, "", d))
+	assert.Equal(t, 2, sc.Checker.Quorum)
+	assert.Equal(t, "github.com/skycoin/skywire", sc.Checker.Checkers[1].Module)
+	assert.Equal(t, "https://github.com/skycoin/skywire", sc.Checker.Checkers[2].Remote)
+	assert.Equal(t, "master", sc.MainBranch)
+
+	invalid := []CheckerConfig{
+		{Type: CompositeCheckerType},
+		{Type: CompositeCheckerType, Combinator: "most", Checkers: []CheckerConfig{{Type: GithubReleaseCheckerType}}},
+		{Type: CompositeCheckerType, Combinator: QuorumCombinator, Quorum: 2, Checkers: []CheckerConfig{{Type: GithubReleaseCheckerType}}},
+		{Type: CompositeCheckerType, Checkers: []CheckerConfig{{}}},
+		{Type: CompositeCheckerType, Checkers: []CheckerConfig{{Type: HTTPManifestCheckerType}}},
+	}
+	for _, cc := range invalid {
+		assert.Error(t, processCompositeCheckerConfig(&ServiceConfig{Repo: sc.Repo, Checker: cc}, "", d))
+	}
+}
@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v2"
 
 	"github.com/skycoin/skywire/pkg/util/pathutil"
+
+	"github.com/skycoin/skywire-updater/pkg/store"
 )
 
 // Config represents an updater service configuration
@@ -21,8 +25,10 @@ type Config struct {
 
 // PathsConfig configures the paths for the updater.
 type PathsConfig struct {
-	DBFile      string `yaml:"db-file"`
-	ScriptsPath string `yaml:"scripts-path"`
+	DBType          store.Type `yaml:"db-type"`
+	DBFile          string     `yaml:"db-file"`
+	ScriptsPath     string     `yaml:"scripts-path"`
+	TranscriptsPath string     `yaml:"transcripts-path"` // Full script output of recent runs is kept here (unless empty).
 }
 
 // InterfacesConfig configures the http interface for the updater.
@@ -40,20 +46,39 @@ type ServicesConfig struct {
 
 // ServiceDefaultsConfig is the configuration that is shared across all services (as default).
 type ServiceDefaultsConfig struct {
-	MainBranch  string   `yaml:"main-branch"`
-	BinDir      string   `yaml:"bin-dir"`
-	Interpreter string   `yaml:"interpreter"`
-	Envs        []string `yaml:"envs"`
+	MainBranch    string                   `yaml:"main-branch"`
+	BinDir        string                   `yaml:"bin-dir"`
+	Interpreter   string                   `yaml:"interpreter"`
+	Envs          []string                 `yaml:"envs"`
+	CheckInterval time.Duration            `yaml:"check-interval"`
+	Policy        PolicyConfig             `yaml:"policy"`
+	Maintenance   MaintenanceConfig        `yaml:"maintenance"`
+	Trust         TrustConfig              `yaml:"trust"`
+	Backups       int                      `yaml:"backups"`
+	Channel       string                   `yaml:"channel"`
+	Channels      map[string]ChannelConfig `yaml:"channels"`
 }
 
 // ServiceConfig represents one of the services to be updated.
 type ServiceConfig struct {
-	Repo        string        `yaml:"repo,omitempty"`
-	MainBranch  string        `yaml:"main-branch,omitempty"`
-	MainProcess string        `yaml:"main-process"`
-	BinDir      string        `yaml:"bin-dir,omitempty"`
-	Checker     CheckerConfig `yaml:"checker"`
-	Updater     UpdaterConfig `yaml:"updater"`
+	Repo          string                   `yaml:"repo,omitempty"`
+	Forge         ForgeConfig              `yaml:"forge,omitempty"` // Forge which hosts the repository and its releases.
+	MainBranch    string                   `yaml:"main-branch,omitempty"`
+	MainProcess   string                   `yaml:"main-process"`
+	BinDir        string                   `yaml:"bin-dir,omitempty"`
+	CheckInterval time.Duration            `yaml:"check-interval,omitempty"` // A negative value disables periodic checks.
+	Policy        PolicyConfig             `yaml:"policy"`
+	Maintenance   MaintenanceConfig        `yaml:"maintenance,omitempty"`
+	Trust         TrustConfig              `yaml:"trust,omitempty"`
+	Backups       int                      `yaml:"backups,omitempty"`    // Number of previous versions of binaries to keep.
+	Constraint    string                   `yaml:"constraint,omitempty"` // Semantic version constraint of the releases to update to.
+	Channel       string                   `yaml:"channel,omitempty"`    // Release channel to follow.
+	Channels      map[string]ChannelConfig `yaml:"channels,omitempty"`   // Release channels in addition to (or overriding) the defaults.
+	Restart       RestartConfig            `yaml:"restart,omitempty"`
+	HealthCheck   HealthCheckConfig        `yaml:"health-check,omitempty"`
+	VersionDetect VersionDetectConfig      `yaml:"version-detect,omitempty"` // Detects the installed version.
+	Checker       CheckerConfig            `yaml:"checker"`
+	Updater       UpdaterConfig            `yaml:"updater"`
 }
 
 // CheckerConfig is the configuration for a service's checker.
@@ -65,6 +90,36 @@ type CheckerConfig struct {
 	Script      string   `yaml:"script,omitempty"`
 	Args        []string `yaml:"args,omitempty"`
 	Envs        []string `yaml:"envs,omitempty"`
+
+	// github-release checker fields (base-url is also used by the oci-registry checker):
+	BaseURL string `yaml:"base-url,omitempty"` // API base URL of the forge (overrides forge.base-url), or of the image registry.
+
+	// http-manifest checker fields:
+	URL     string               `yaml:"url,omitempty"`     // URL of the release manifest.
+	Format  ManifestFormat       `yaml:"format,omitempty"`  // Determined by the response if unspecified.
+	Headers map[string]string    `yaml:"headers,omitempty"` // Request headers (such as for authentication). Environment variables in values are expanded.
+	Fields  ManifestFieldsConfig `yaml:"fields,omitempty"`
+
+	// git-ref checker fields (remote is also used by the goproxy checker, and
+	// tags by the oci-registry checker):
+	Remote string `yaml:"remote,omitempty"` // URL of the remote repository ('https://{repo}' if unspecified).
+	Tags   string `yaml:"tags,omitempty"`   // Pattern of the tags to track (the head of main-branch is tracked if unspecified).
+
+	// goproxy checker fields:
+	Module    string `yaml:"module,omitempty"`    // Module path (derived from repo if unspecified).
+	GoProxy   string `yaml:"goproxy,omitempty"`   // GOPROXY list (the GOPROXY env, or "https://proxy.golang.org,direct" if unspecified).
+	GoNoProxy string `yaml:"gonoproxy,omitempty"` // Patterns of modules looked up directly (the GONOPROXY or GOPRIVATE env if unspecified).
+
+	// oci-registry checker fields:
+	Image    string `yaml:"image,omitempty"`    // Image without tag: '[<registry>/]<repository>' (on docker hub if the registry is unspecified).
+	Tag      string `yaml:"tag,omitempty"`      // Floating tag whose digest is tracked ("latest" if neither tag nor tags are specified).
+	Username string `yaml:"username,omitempty"` // Registry username. Environment variables are expanded.
+	Password string `yaml:"password,omitempty"` // Registry password or access token. Environment variables are expanded.
+
+	// composite checker fields:
+	Checkers   []CheckerConfig   `yaml:"checkers,omitempty"`   // Child checkers, which check the same service.
+	Combinator CheckerCombinator `yaml:"combinator,omitempty"` // How the results of the child checkers are combined ("all" if unspecified).
+	Quorum     int               `yaml:"quorum,omitempty"`     // Number of child checkers which need to report an update if combinator is "quorum" (a majority if unspecified).
 }
 
 // UpdaterConfig is the configuration for a service's updater.
@@ -76,6 +131,13 @@ type UpdaterConfig struct {
 	Script      string   `yaml:"script,omitempty"`
 	Args        []string `yaml:"args,omitempty"`
 	Envs        []string `yaml:"envs,omitempty"`
+
+	// github-release-asset updater fields:
+	BaseURL  string   `yaml:"base-url,omitempty"` // API base URL of the forge (overrides forge.base-url).
+	Asset    string   `yaml:"asset,omitempty"`    // Asset name pattern, with {os}, {arch} and {version} placeholders.
+	Binaries []string `yaml:"binaries,omitempty"` // Binaries to install from the asset.
+
+	Checksums string `yaml:"checksums,omitempty"` // Checksum manifest asset name pattern.
 }
 
 // NewConfig returns a config with default values (from the provided root
@@ -83,8 +145,10 @@ type UpdaterConfig struct {
 func NewConfig(rootDir, binDir string) *Config {
 	return &Config{
 		Paths: PathsConfig{
-			DBFile:      filepath.Join(rootDir, "db.json"),
-			ScriptsPath: filepath.Join(rootDir, "scripts"),
+			DBType:          store.JSONType,
+			DBFile:          filepath.Join(rootDir, "db.json"),
+			ScriptsPath:     filepath.Join(rootDir, "scripts"),
+			TranscriptsPath: filepath.Join(rootDir, "transcripts"),
 		},
 		Interfaces: InterfacesConfig{
 			Addr:       ":7280",
@@ -93,10 +157,14 @@ func NewConfig(rootDir, binDir string) *Config {
 		},
 		Services: ServicesConfig{
 			Defaults: ServiceDefaultsConfig{
-				MainBranch:  "master",
-				BinDir:      binDir,
-				Interpreter: "/bin/bash",
-				Envs:        []string{},
+				MainBranch:    "master",
+				BinDir:        binDir,
+				Interpreter:   "/bin/bash",
+				Envs:          []string{},
+				CheckInterval: time.Hour,
+				Policy:        PolicyConfig{Mode: NotifyOnlyPolicy},
+				Backups:       DefaultBackups,
+				Channel:       StableChannel,
 			},
 			Services: make(map[string]*ServiceConfig),
 		},
@@ -152,30 +220,94 @@ func (c *Config) Parse(path string) error {
 	return nil
 }
 
+// Checks the checker of a service for errors and fills unspecified fields
+// with default values.
+func processCheckerConfig(sc *ServiceConfig, scriptsPath string, d *ServiceDefaultsConfig) error {
+	switch sc.Checker.Type {
+	case ScriptCheckerType:
+		if sc.Checker.Interpreter == "" {
+			sc.Checker.Interpreter = d.Interpreter
+		}
+		if sc.Checker.Script == "" {
+			return errors.New("checker.script needs to be defined")
+		}
+		if scriptsPath != "" {
+			sc.Checker.Script = filepath.Join(scriptsPath, sc.Checker.Script)
+		}
+		if _, err := os.Stat(sc.Checker.Script); err != nil {
+			return fmt.Errorf("checker.script cannot be accessed: %s", err.Error())
+		}
+	case GithubReleaseCheckerType:
+		domain, _ := splitRepo(sc.Repo)
+		return resolveForgeConfig(&sc.Forge, domain)
+	case HTTPManifestCheckerType:
+		return processManifestCheckerConfig(&sc.Checker)
+	case GitRefCheckerType:
+		return processGitRefCheckerConfig(sc, d)
+	case GoProxyCheckerType:
+		return processGoProxyCheckerConfig(sc)
+	case OCIRegistryCheckerType:
+		return processOCIRegistryCheckerConfig(&sc.Checker)
+	case CompositeCheckerType:
+		return processCompositeCheckerConfig(sc, scriptsPath, d)
+	}
+	return nil
+}
+
 // Checks for errors and fills unspecified fields with default values.
 func processServiceConfig(sc *ServiceConfig, scriptsPath string, d *ServiceDefaultsConfig) error {
 	if sc.BinDir == "" {
 		sc.BinDir = d.BinDir
 	}
+	if sc.CheckInterval == 0 {
+		sc.CheckInterval = d.CheckInterval
+	}
+	if sc.Backups == 0 {
+		sc.Backups = d.Backups
+	}
+	if sc.Backups < 0 {
+		return errors.New("backups cannot be negative")
+	}
+	if err := processPolicyConfig(&sc.Policy, &d.Policy); err != nil {
+		return err
+	}
+	if err := processMaintenanceConfig(&sc.Maintenance, &d.Maintenance); err != nil {
+		return err
+	}
+	if err := processTrustConfig(&sc.Trust, &d.Trust, sc); err != nil {
+		return err
+	}
+	if sc.Constraint != "" {
+		if _, err := ParseConstraint(sc.Constraint); err != nil {
+			return err
+		}
+	}
+	if err := processChannelConfig(sc, d); err != nil {
+		return err
+	}
+	if err := processRestartConfig(&sc.Restart, sc); err != nil {
+		return err
+	}
+	if err := processHealthCheckConfig(&sc.HealthCheck, sc, scriptsPath, d); err != nil {
+		return err
+	}
+	if err := processVersionDetectConfig(&sc.VersionDetect, sc, scriptsPath, d); err != nil {
+		return err
+	}
+	if sc.Repo != "" && sc.Checker.Type == "" {
+		sc.Checker.Type = ScriptCheckerType
+	}
+	if err := processCheckerConfig(sc, scriptsPath, d); err != nil {
+		return err
+	}
 	if sc.Repo != "" {
 		if sc.MainBranch == "" {
 			sc.MainBranch = d.MainBranch
 		}
-		if sc.Checker.Type == "" {
-			sc.Checker.Type = ScriptCheckerType
-		}
-		if sc.Checker.Type == ScriptCheckerType {
-			if sc.Checker.Interpreter == "" {
-				sc.Checker.Interpreter = d.Interpreter
-			}
-			if sc.Checker.Script == "" {
-				return errors.New("checker.script needs to be defined")
-			}
-			if scriptsPath != "" {
-				sc.Checker.Script = filepath.Join(scriptsPath, sc.Checker.Script)
-			}
-			if _, err := os.Stat(sc.Checker.Script); err != nil {
-				return fmt.Errorf("checker.script cannot be accessed: %s", err.Error())
+		if sc.Updater.Type == GithubReleaseAssetUpdaterType {
+			domain, _ := splitRepo(sc.Repo)
+			if err := resolveForgeConfig(&sc.Forge, domain); err != nil {
+				return err
 			}
 		}
 		if sc.Updater.Type == "" {
@@ -195,6 +327,26 @@ func processServiceConfig(sc *ServiceConfig, scriptsPath string, d *ServiceDefau
 				return fmt.Errorf("updater.script cannot be accessed: %s", err.Error())
 			}
 		}
+		if sc.Updater.Type == GithubReleaseAssetUpdaterType {
+			if sc.Updater.Asset == "" {
+				sc.Updater.Asset = DefaultAssetPattern
+			}
+			if _, err := path.Match(sc.Updater.Asset, ""); err != nil {
+				return fmt.Errorf("updater.asset is invalid: %s", err.Error())
+			}
+			if _, err := path.Match(sc.Updater.Checksums, ""); err != nil {
+				return fmt.Errorf("updater.checksums is invalid: %s", err.Error())
+			}
+			if len(sc.Updater.Binaries) == 0 {
+				if sc.MainProcess == "" {
+					return errors.New("updater.binaries or main-process needs to be defined")
+				}
+				sc.Updater.Binaries = []string{sc.MainProcess}
+			}
+		}
+		if sc.Trust.Enabled() && !verifiesTrust(sc) {
+			return fmt.Errorf("trust requires bin-dir with updater type '%s', so that binaries are verified before they are installed", sc.Updater.Type)
+		}
 	}
 	return nil
 }
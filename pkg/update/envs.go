@@ -33,6 +33,10 @@ const (
 	// EnvGithubAccessToken can be used by checkers or updaters scripts for
 	// github authentication (needs to be set manually).
 	EnvGithubAccessToken = "SWU_GITHUB_ACCESS_TOKEN" //nolint:gosec
+
+	// EnvResultFile can be used by scripts to determine the file to write
+	// their structured result to (see ScriptResult).
+	EnvResultFile = "SWU_RESULT_FILE"
 )
 
 // MakeEnv makes an environment variable string of format '<key>=<value>'.
@@ -63,6 +67,30 @@ func UpdaterEnvs(g *ServiceDefaultsConfig, s *ServiceConfig, toVersion string) [
 	return envs
 }
 
+// HealthCheckEnvs outputs envs for a given health check script of service.
+// It builds in this order:
+// 1. Envs from Defaults.
+// 2. Envs from Service.
+// 3. Envs from Service.HealthCheck.
+// 4. Add SWU_TO_VERSION env.
+func HealthCheckEnvs(g *ServiceDefaultsConfig, s *ServiceConfig, toVersion string) []string {
+	envs := append(srvEnvs(g, s), s.HealthCheck.Envs...)
+	if toVersion != "" {
+		envs = append(envs, MakeEnv(EnvToVersion, toVersion))
+	}
+	return envs
+}
+
+// VersionDetectEnvs outputs envs for a given version detection command or
+// script of service.
+// It builds in this order:
+// 1. Envs from Defaults.
+// 2. Envs from Service.
+// 3. Envs from Service.VersionDetect.
+func VersionDetectEnvs(g *ServiceDefaultsConfig, s *ServiceConfig) []string {
+	return append(srvEnvs(g, s), s.VersionDetect.Envs...)
+}
+
 func srvEnvs(g *ServiceDefaultsConfig, s *ServiceConfig) []string {
 	envs := append(os.Environ(), g.Envs...)
 	if s.Repo != "" {
@@ -0,0 +1,357 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+)
+
+// ForgeType is the type of a forge, which hosts the repository and releases of
+// a service.
+type ForgeType string
+
+const (
+	// GithubForge is github.com.
+	GithubForge = ForgeType("github")
+
+	// GithubEnterpriseForge is a GitHub Enterprise server.
+	GithubEnterpriseForge = ForgeType("github-enterprise")
+
+	// GiteaForge is a Gitea server.
+	GiteaForge = ForgeType("gitea")
+
+	// GitlabForge is gitlab.com or a self-hosted GitLab server.
+	GitlabForge = ForgeType("gitlab")
+)
+
+var forgeTypes = []ForgeType{
+	GithubForge,
+	GithubEnterpriseForge,
+	GiteaForge,
+	GitlabForge,
+}
+
+// DefaultGithubBaseURL is the base URL of the github API.
+const DefaultGithubBaseURL = "https://api.github.com"
+
+// ForgeConfig configures the forge which hosts the releases of a service.
+type ForgeConfig struct {
+	Type    ForgeType `yaml:"type,omitempty"`     // Determined by the domain of 'repo' if unspecified.
+	BaseURL string    `yaml:"base-url,omitempty"` // API base URL (determined by the type and the domain of 'repo' if unspecified).
+	Token   string    `yaml:"token,omitempty"`    // API access token. Environment variables are expanded.
+}
+
+// Forge obtains the releases of a repository.
+type Forge interface {
+	// LatestRelease obtains the latest release which is not a pre-release.
+	LatestRelease(ctx context.Context) (*GitReleaseBody, error)
+
+	// Releases lists the most recent releases.
+	Releases(ctx context.Context) ([]GitReleaseBody, error)
+
+	// Release obtains the release of the given tag.
+	Release(ctx context.Context, tag string) (*GitReleaseBody, error)
+
+	// Authorize adds credentials to a request to the forge (such as a
+	// download of a release asset). Credentials are only added to requests to
+	// the host of the forge's API or of the repository.
+	Authorize(req *http.Request)
+}
+
+// NewForge creates the Forge of the given repository (of format
+// '<domain>/<owner>/<name>').
+func NewForge(l *logging.Logger, repo string, c ForgeConfig) (Forge, error) {
+	domain, repoPath := splitRepo(repo)
+	if err := resolveForgeConfig(&c, domain); err != nil {
+		return nil, err
+	}
+	if domain == "" && c.Type == GithubForge {
+		domain = "github.com"
+	}
+	f := &githubForge{l: l, c: c, domain: domain, repo: repoPath, listQuery: "per_page=100"}
+	switch c.Type {
+	case GithubForge, GithubEnterpriseForge:
+		return f, nil
+	case GiteaForge:
+		f.listQuery = "limit=50"
+		return f, nil
+	case GitlabForge:
+		return &gitlabForge{l: l, c: c, domain: domain, project: url.PathEscape(repoPath)}, nil
+	default:
+		return nil, fmt.Errorf("invalid forge type '%s' when expecting: %v", c.Type, forgeTypes)
+	}
+}
+
+// newServiceForge creates the Forge of a service, and panics on failure. The
+// forge's API base URL is overridden by baseURL if it is not empty.
+func newServiceForge(l *logging.Logger, c ServiceConfig, baseURL string) Forge {
+	if baseURL != "" {
+		c.Forge.BaseURL = baseURL
+	}
+	f, err := NewForge(l, c.Repo, c.Forge)
+	if err != nil {
+		l.WithError(err).Fatal("Invalid forge.")
+	}
+	return f
+}
+
+// splitRepo splits a repository into its domain and its path. The domain is
+// empty if the repository does not start with one.
+func splitRepo(repo string) (string, string) {
+	repo = strings.Trim(strings.TrimPrefix(strings.TrimPrefix(repo, "https://"), "http://"), "/")
+	parts := strings.SplitN(repo, "/", 2)
+	if len(parts) == 2 && strings.Contains(parts[0], ".") {
+		return parts[0], strings.TrimSuffix(parts[1], ".git")
+	}
+	return "", strings.TrimSuffix(repo, ".git")
+}
+
+// resolveForgeConfig fills the type and API base URL of a forge from the domain
+// of the repository, if unspecified.
+func resolveForgeConfig(c *ForgeConfig, domain string) error {
+	if c.Type == "" {
+		switch {
+		case domain == "" || domain == "github.com":
+			c.Type = GithubForge
+		case domain == "gitlab.com" || strings.Contains(domain, "gitlab"):
+			c.Type = GitlabForge
+		case domain == "gitea.com" || domain == "codeberg.org" || strings.Contains(domain, "gitea"):
+			c.Type = GiteaForge
+		case strings.Contains(domain, "github"):
+			c.Type = GithubEnterpriseForge
+		default:
+			return fmt.Errorf("forge of repo domain '%s' cannot be determined, forge.type needs to be defined", domain)
+		}
+	}
+	if c.BaseURL != "" {
+		return nil
+	}
+	if domain == "" && c.Type != GithubForge {
+		return fmt.Errorf("forge.base-url or the domain of repo needs to be defined for forge type '%s'", c.Type)
+	}
+	switch c.Type {
+	case GithubForge:
+		c.BaseURL = DefaultGithubBaseURL
+	case GithubEnterpriseForge:
+		c.BaseURL = "https://" + domain + "/api/v3"
+	case GiteaForge:
+		c.BaseURL = "https://" + domain + "/api/v1"
+	case GitlabForge:
+		c.BaseURL = "https://" + domain + "/api/v4"
+	default:
+		return fmt.Errorf("invalid forge.type '%s' when expecting: %v", c.Type, forgeTypes)
+	}
+	return nil
+}
+
+// isForgeHost returns whether the request is to the host of the forge's API or
+// of the repository (with the given domain), which may be sent credentials.
+func isForgeHost(c ForgeConfig, domain string, req *http.Request) bool {
+	if base, err := url.Parse(c.BaseURL); err == nil && base.Host != "" && strings.EqualFold(base.Host, req.URL.Host) {
+		return true
+	}
+	return domain != "" && strings.EqualFold(domain, req.URL.Host)
+}
+
+// forgeClient returns a client for requests to the forge, which re-authorizes
+// redirected requests so that credentials are not passed on to other hosts.
+func forgeClient(f Forge) *http.Client {
+	return &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			req.Header.Del("Authorization")
+			req.Header.Del("PRIVATE-TOKEN")
+			f.Authorize(req)
+			return nil
+		},
+	}
+}
+
+// fetchForge decodes the JSON response of the given endpoint of a forge API.
+func fetchForge(ctx context.Context, l *logging.Logger, f Forge, baseURL, endpoint string, v interface{}) error {
+	u := strings.TrimSuffix(baseURL, "/") + "/" + endpoint
+	l.Infoln("Request URL:", u)
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	f.Authorize(req)
+	req.Header.Add("Accept", "application/json")
+	resp, err := forgeClient(f).Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("unrecognised json body: %s", err)
+	}
+	return nil
+}
+
+// githubForge implements Forge for github, GitHub Enterprise and Gitea (which
+// provides a github compatible releases API).
+type githubForge struct {
+	l         *logging.Logger
+	c         ForgeConfig
+	domain    string // Domain of the repository.
+	repo      string // Path of the repository: '<owner>/<name>'.
+	listQuery string // Query of release listings.
+}
+
+func (f *githubForge) fetch(ctx context.Context, endpoint string, v interface{}) error {
+	return fetchForge(ctx, f.l, f, f.c.BaseURL, path.Join("repos", f.repo, endpoint), v)
+}
+
+func (f *githubForge) LatestRelease(ctx context.Context) (*GitReleaseBody, error) {
+	var body GitReleaseBody
+	if err := f.fetch(ctx, "releases/latest", &body); err != nil {
+		return nil, err
+	}
+	return &body, nil
+}
+
+func (f *githubForge) Releases(ctx context.Context) ([]GitReleaseBody, error) {
+	var bodies []GitReleaseBody
+	if err := f.fetch(ctx, "releases?"+f.listQuery, &bodies); err != nil {
+		return nil, err
+	}
+	return bodies, nil
+}
+
+func (f *githubForge) Release(ctx context.Context, tag string) (*GitReleaseBody, error) {
+	var body GitReleaseBody
+	if err := f.fetch(ctx, path.Join("releases", "tags", url.PathEscape(tag)), &body); err != nil {
+		return nil, err
+	}
+	return &body, nil
+}
+
+// Authorize uses the forge's token, or the SWU_GITHUB_USERNAME and
+// SWU_GITHUB_ACCESS_TOKEN environment variables for github forges.
+func (f *githubForge) Authorize(req *http.Request) {
+	if !isForgeHost(f.c, f.domain, req) {
+		return
+	}
+	if token := os.ExpandEnv(f.c.Token); token != "" {
+		req.Header.Set("Authorization", "token "+token)
+		return
+	}
+	if f.c.Type == GiteaForge {
+		return
+	}
+	usr, usrOK := os.LookupEnv(EnvGithubUsername)
+	pac, pacOK := os.LookupEnv(EnvGithubAccessToken)
+	if usrOK && pacOK {
+		req.SetBasicAuth(usr, pac)
+	}
+}
+
+// gitlabForge implements Forge for GitLab.
+type gitlabForge struct {
+	l       *logging.Logger
+	c       ForgeConfig
+	domain  string // Domain of the project.
+	project string // Escaped path of the project.
+}
+
+// gitlabRelease is a release as returned by the GitLab API.
+type gitlabRelease struct {
+	TagName     string `json:"tag_name"`
+	Description string `json:"description"`
+	ReleasedAt  string `json:"released_at"`
+	Upcoming    bool   `json:"upcoming_release"`
+	Links       struct {
+		Self string `json:"self"`
+	} `json:"_links"`
+	Assets struct {
+		Links []struct {
+			Name           string `json:"name"`
+			URL            string `json:"url"`
+			DirectAssetURL string `json:"direct_asset_url"`
+		} `json:"links"`
+	} `json:"assets"`
+}
+
+// body converts the release to the format of github releases. Upcoming
+// releases are pre-releases.
+func (r gitlabRelease) body() GitReleaseBody {
+	body := GitReleaseBody{
+		URL:        r.Links.Self,
+		TagName:    r.TagName,
+		PubAt:      r.ReleasedAt,
+		Body:       r.Description,
+		Prerelease: r.Upcoming,
+	}
+	for _, link := range r.Assets.Links {
+		asset := GitReleaseAsset{Name: link.Name, DownloadURL: link.DirectAssetURL}
+		if asset.DownloadURL == "" {
+			asset.DownloadURL = link.URL
+		}
+		body.Assets = append(body.Assets, asset)
+	}
+	return body
+}
+
+func (f *gitlabForge) fetch(ctx context.Context, endpoint string, v interface{}) error {
+	return fetchForge(ctx, f.l, f, f.c.BaseURL, "projects/"+f.project+"/"+endpoint, v)
+}
+
+// LatestRelease obtains the most recently released release which is not
+// upcoming.
+func (f *gitlabForge) LatestRelease(ctx context.Context) (*GitReleaseBody, error) {
+	bodies, err := f.Releases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range bodies {
+		if !bodies[i].Prerelease {
+			return &bodies[i], nil
+		}
+	}
+	return nil, fmt.Errorf("project has no releases")
+}
+
+// Releases lists the most recent releases, ordered by release time.
+func (f *gitlabForge) Releases(ctx context.Context) ([]GitReleaseBody, error) {
+	var releases []gitlabRelease
+	if err := f.fetch(ctx, "releases?per_page=100", &releases); err != nil {
+		return nil, err
+	}
+	bodies := make([]GitReleaseBody, len(releases))
+	for i, r := range releases {
+		bodies[i] = r.body()
+	}
+	return bodies, nil
+}
+
+func (f *gitlabForge) Release(ctx context.Context, tag string) (*GitReleaseBody, error) {
+	var release gitlabRelease
+	if err := f.fetch(ctx, "releases/"+url.PathEscape(tag), &release); err != nil {
+		return nil, err
+	}
+	body := release.body()
+	return &body, nil
+}
+
+// Authorize uses the forge's token as a private token.
+func (f *gitlabForge) Authorize(req *http.Request) {
+	if !isForgeHost(f.c, f.domain, req) {
+		return
+	}
+	if token := os.ExpandEnv(f.c.Token); token != "" {
+		req.Header.Set("PRIVATE-TOKEN", token)
+	}
+}
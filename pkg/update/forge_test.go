@@ -0,0 +1,205 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-updater/pkg/store"
+)
+
+func TestResolveForgeConfig(t *testing.T) {
+	cases := []struct {
+		repo     string
+		c        ForgeConfig
+		wantType ForgeType
+		wantURL  string
+		wantPath string
+	}{
+		{"github.com/skycoin/skywire", ForgeConfig{}, GithubForge, DefaultGithubBaseURL, "skycoin/skywire"},
+		{"skycoin/skywire", ForgeConfig{}, GithubForge, DefaultGithubBaseURL, "skycoin/skywire"},
+		{"github.example.com/skycoin/skywire", ForgeConfig{}, GithubEnterpriseForge, "https://github.example.com/api/v3", "skycoin/skywire"},
+		{"gitea.example.com/skycoin/skywire.git", ForgeConfig{}, GiteaForge, "https://gitea.example.com/api/v1", "skycoin/skywire"},
+		{"gitlab.com/skycoin/mirrors/skywire", ForgeConfig{}, GitlabForge, "https://gitlab.com/api/v4", "skycoin/mirrors/skywire"},
+		{"git.example.com/skycoin/skywire", ForgeConfig{Type: GiteaForge}, GiteaForge, "https://git.example.com/api/v1", "skycoin/skywire"},
+		{"git.example.com/skycoin/skywire", ForgeConfig{Type: GitlabForge, BaseURL: "http://10.0.0.2/api/v4"}, GitlabForge, "http://10.0.0.2/api/v4", "skycoin/skywire"},
+		{"git.example.com/skycoin/skywire", ForgeConfig{}, "", "", ""},
+		{"skycoin/skywire", ForgeConfig{Type: GiteaForge}, "", "", ""},
+	}
+	for _, tc := range cases {
+		domain, repoPath := splitRepo(tc.repo)
+		err := resolveForgeConfig(&tc.c, domain)
+		if tc.wantType == "" {
+			assert.Error(t, err, tc.repo)
+			continue
+		}
+		require.NoError(t, err, tc.repo)
+		assert.Equal(t, tc.wantType, tc.c.Type, tc.repo)
+		assert.Equal(t, tc.wantURL, tc.c.BaseURL, tc.repo)
+		assert.Equal(t, tc.wantPath, repoPath, tc.repo)
+	}
+}
+
+func TestForge_gitea(t *testing.T) {
+	releases := []GitReleaseBody{
+		{TagName: "v0.3.0-rc.1", PubAt: "2019-05-01T10:00:00Z", Prerelease: true},
+		{TagName: "v0.2.0", PubAt: "2019-04-01T10:00:00Z", Assets: []GitReleaseAsset{{Name: "skywire.tar.gz", DownloadURL: "https://gitea.example.com/attachments/1"}}},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/repos/skycoin/skywire/releases", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "50", r.URL.Query().Get("limit"))
+		require.NoError(t, json.NewEncoder(w).Encode(releases))
+	})
+	mux.HandleFunc("/api/v1/repos/skycoin/skywire/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewEncoder(w).Encode(releases[1]))
+	})
+	mux.HandleFunc("/api/v1/repos/skycoin/skywire/releases/tags/v0.2.0", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewEncoder(w).Encode(releases[1]))
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	f, err := NewForge(logging.MustGetLogger("test"), "gitea.example.com/skycoin/skywire",
+		ForgeConfig{BaseURL: srv.URL + "/api/v1", Token: "secret"})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	all, err := f.Releases(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, releases, all)
+	latest, err := f.LatestRelease(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "v0.2.0", latest.TagName)
+	release, err := f.Release(ctx, "v0.2.0")
+	require.NoError(t, err)
+	assert.Equal(t, releases[1], *release)
+
+	f, err = NewForge(logging.MustGetLogger("test"), "gitea.example.com/skycoin/skywire", ForgeConfig{BaseURL: srv.URL + "/api/v1"})
+	require.NoError(t, err)
+	_, err = f.Releases(ctx)
+	assert.Error(t, err)
+}
+
+func TestForge_gitlab(t *testing.T) {
+	const releases = `[
+	{"tag_name": "v0.3.0", "released_at": "2019-06-01T10:00:00.000Z", "upcoming_release": true},
+	{
+		"tag_name": "v0.2.0",
+		"description": "Fixes.",
+		"released_at": "2019-04-01T10:00:00.000Z",
+		"_links": {"self": "https://gitlab.example.com/skycoin/mirrors/skywire/-/releases/v0.2.0"},
+		"assets": {"links": [{"name": "skywire.tar.gz", "url": "https://gitlab.example.com/uploads/skywire.tar.gz"}]}
+	}
+]`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.RawPath {
+		case "/api/v4/projects/skycoin%2Fmirrors%2Fskywire/releases":
+			_, _ = w.Write([]byte(releases)) //nolint:errcheck
+		case "/api/v4/projects/skycoin%2Fmirrors%2Fskywire/releases/v0.2.0":
+			var all []json.RawMessage
+			require.NoError(t, json.Unmarshal([]byte(releases), &all))
+			_, _ = w.Write(all[1]) //nolint:errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	f, err := NewForge(logging.MustGetLogger("test"), "gitlab.example.com/skycoin/mirrors/skywire",
+		ForgeConfig{BaseURL: srv.URL + "/api/v4", Token: "secret"})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	want := GitReleaseBody{
+		URL:     "https://gitlab.example.com/skycoin/mirrors/skywire/-/releases/v0.2.0",
+		TagName: "v0.2.0",
+		PubAt:   "2019-04-01T10:00:00.000Z",
+		Body:    "Fixes.",
+		Assets:  []GitReleaseAsset{{Name: "skywire.tar.gz", DownloadURL: "https://gitlab.example.com/uploads/skywire.tar.gz"}},
+	}
+	all, err := f.Releases(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.True(t, all[0].Prerelease)
+	assert.Equal(t, want, all[1])
+	latest, err := f.LatestRelease(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, want, *latest)
+	release, err := f.Release(ctx, "v0.2.0")
+	require.NoError(t, err)
+	assert.Equal(t, want, *release)
+
+	// The release checker works with any forge.
+	db, rmDB := prepareDB(t)
+	defer rmDB()
+	require.NoError(t, db.SetServiceLastUpdate("srv", store.Update{Tag: "v0.1.0"}))
+	c := ServiceConfig{
+		Repo:    "gitlab.example.com/skycoin/mirrors/skywire",
+		Forge:   ForgeConfig{BaseURL: srv.URL + "/api/v4", Token: "secret"},
+		Checker: CheckerConfig{Type: GithubReleaseCheckerType},
+	}
+	r, err := NewGithubReleaseChecker(db, "srv", c).Check(ctx)
+	require.NoError(t, err)
+	assert.True(t, r.HasUpdate)
+	assert.Equal(t, "v0.2.0", r.Version)
+}
+
+func TestForge_Authorize(t *testing.T) {
+	// Release links of GitLab may point to any host, which are not sent the token.
+	var extAuthorized bool
+	ext := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		extAuthorized = r.Header.Get("PRIVATE-TOKEN") != "" || r.Header.Get("Authorization") != ""
+		_, _ = w.Write([]byte("asset")) //nolint:errcheck
+	}))
+	defer ext.Close()
+	var authorized bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorized = r.Header.Get("PRIVATE-TOKEN") == "secret"
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, ext.URL+"/asset", http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte("asset")) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	au := NewGithubAssetUpdater("srv", ServiceConfig{
+		Repo:  "gitlab.example.com/skycoin/skywire",
+		Forge: ForgeConfig{BaseURL: srv.URL + "/api/v4", Token: "secret"},
+	})
+	ctx := context.Background()
+
+	require.NoError(t, au.download(ctx, srv.URL+"/asset", filepath.Join(dir, "1")))
+	assert.True(t, authorized)
+
+	require.NoError(t, au.download(ctx, ext.URL+"/asset", filepath.Join(dir, "2")))
+	assert.False(t, extAuthorized)
+
+	require.NoError(t, au.download(ctx, srv.URL+"/redirect", filepath.Join(dir, "3")))
+	assert.True(t, authorized)
+	assert.False(t, extAuthorized)
+}
@@ -0,0 +1,204 @@
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+
+	"github.com/skycoin/skywire-updater/pkg/store"
+)
+
+// DefaultGitTimeout is the timeout of git commands.
+const DefaultGitTimeout = time.Minute
+
+// gitRef is a ref of a remote git repository.
+type gitRef struct {
+	name   string // Short name of the ref (branch or tag name).
+	commit string
+}
+
+// GitRefChecker checks for available updates by listing the refs of the
+// service's remote git repository with 'git ls-remote'. It tracks the head of
+// the service's main branch, or the newest tag matching a pattern.
+//
+// The commit which the service is known to be at is recorded in the store. It
+// is the commit seen by the first check, and is advanced once the service is
+// updated to (or detected at) the tracked ref. An update is available while the
+// tracked ref points to another commit.
+type GitRefChecker struct {
+	srvName string
+	c       ServiceConfig
+	db      store.Store
+	log     *logging.Logger
+}
+
+// NewGitRefChecker creates a new GitRefChecker.
+func NewGitRefChecker(db store.Store, srvName string, c ServiceConfig) *GitRefChecker {
+	if c.Checker.Remote == "" {
+		c.Checker.Remote = gitRemote(c.Repo)
+	}
+	return &GitRefChecker{
+		srvName: srvName,
+		c:       c,
+		db:      db,
+		log:     logging.MustGetLogger("git-ref-checker." + srvName),
+	}
+}
+
+// Check checks for updates.
+func (gc *GitRefChecker) Check(ctx context.Context) (*Release, error) {
+	ref, err := gc.trackedRef(ctx)
+	if err != nil {
+		return nil, err
+	}
+	release := &Release{
+		Version:     ref.commit,
+		Timestamp:   time.Now(),
+		CheckerType: GitRefCheckerType,
+	}
+	if gc.c.Checker.Tags != "" {
+		release.Version = ref.name
+	}
+	progress(ctx, gc.log, "Ref '%s' is at commit %s.", ref.name, ref.commit)
+
+	last := gc.db.ServiceLastUpdate(gc.srvName)
+	installed := last.Installed()
+	switch {
+	case last.Commit == "" || installed == release.Version || installed == ref.commit:
+		if last.Commit != ref.commit {
+			last.Commit = ref.commit
+			if err := gc.db.SetServiceLastUpdate(gc.srvName, last); err != nil {
+				gc.log.WithError(err).Error("Failed to record commit.")
+			}
+		}
+	case last.IsRolledBack(release.Version):
+		gc.log.Infof("Release '%s' is not offered as it was rolled back from.", release.Version)
+	default:
+		release.HasUpdate = ref.commit != last.Commit
+	}
+	return release, nil
+}
+
+// trackedRef obtains the tracked ref: the newest tag matching the tag pattern
+// if one is configured, or else the head of the main branch.
+func (gc *GitRefChecker) trackedRef(ctx context.Context) (*gitRef, error) {
+	cc := gc.c.Checker
+	if cc.Tags == "" {
+		refs, err := lsRemote(ctx, cc.Remote, "--heads", "refs/heads/"+gc.c.MainBranch)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			if ref.name == gc.c.MainBranch {
+				return &ref, nil
+			}
+		}
+		return nil, fmt.Errorf("branch '%s' not found in '%s'", gc.c.MainBranch, cc.Remote)
+	}
+
+	refs, err := lsRemote(ctx, cc.Remote, "--tags")
+	if err != nil {
+		return nil, err
+	}
+	var newest *gitRef
+	for i, ref := range refs {
+		if ok, err := path.Match(cc.Tags, ref.name); err != nil || !ok {
+			continue
+		}
+		if newest == nil || newerTag(ref.name, newest.name) {
+			newest = &refs[i]
+		}
+	}
+	if newest == nil {
+		return nil, fmt.Errorf("no tag matching '%s' found in '%s'", cc.Tags, cc.Remote)
+	}
+	return newest, nil
+}
+
+// lsRemote lists the refs of a remote repository of the given kind ("--heads"
+// or "--tags") which match the patterns. The commits of annotated tags are
+// those they point to.
+func lsRemote(ctx context.Context, remote, kind string, patterns ...string) ([]gitRef, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultGitTimeout)
+	defer cancel()
+	args := append([]string{"ls-remote", kind, remote}, patterns...)
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...) //nolint:gosec
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git ls-remote: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var refs []gitRef
+	peeled := make(map[string]string)
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		commit, name := fields[0], fields[1]
+		name = strings.TrimPrefix(strings.TrimPrefix(name, "refs/heads/"), "refs/tags/")
+		if strings.HasSuffix(name, "^{}") {
+			peeled[strings.TrimSuffix(name, "^{}")] = commit
+			continue
+		}
+		refs = append(refs, gitRef{name: name, commit: commit})
+	}
+	for i, ref := range refs {
+		if commit, ok := peeled[ref.name]; ok {
+			refs[i].commit = commit
+		}
+	}
+	return refs, scanner.Err()
+}
+
+// newerTag returns whether tag a is newer than tag b. Tags are compared as
+// semantic versions, or lexically if either is not a semantic version.
+func newerTag(a, b string) bool {
+	av, aErr := ParseVersion(a)
+	bv, bErr := ParseVersion(b)
+	if aErr == nil && bErr == nil {
+		return av.Compare(bv) > 0
+	}
+	return a > b
+}
+
+// gitRemote derives the URL of the remote git repository from the service's
+// repo.
+func gitRemote(repo string) string {
+	if strings.Contains(repo, "://") || strings.HasPrefix(repo, "git@") || strings.HasPrefix(repo, "/") {
+		return repo
+	}
+	return "https://" + repo
+}
+
+// Checks for errors and fills unspecified fields with default values.
+func processGitRefCheckerConfig(sc *ServiceConfig, d *ServiceDefaultsConfig) error {
+	if sc.MainBranch == "" {
+		sc.MainBranch = d.MainBranch
+	}
+	if sc.Checker.Remote == "" {
+		if sc.Repo == "" {
+			return errors.New("checker.remote or repo needs to be defined")
+		}
+		sc.Checker.Remote = gitRemote(sc.Repo)
+	}
+	if sc.Checker.Tags == "" && sc.MainBranch == "" {
+		return errors.New("checker.tags or main-branch needs to be defined")
+	}
+	if _, err := path.Match(sc.Checker.Tags, ""); err != nil {
+		return fmt.Errorf("checker.tags is invalid: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package update
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-updater/pkg/store"
+)
+
+// gitRepo is a working repository which pushes to a local bare repository.
+type gitRepo struct {
+	t      *testing.T
+	dir    string
+	remote string // file:// URL of the bare repository.
+}
+
+func prepareGitRepo(t *testing.T) (*gitRepo, func()) {
+	parent, err := ioutil.TempDir(os.TempDir(), "")
+	require.NoError(t, err)
+	r := &gitRepo{t: t, dir: filepath.Join(parent, "work"), remote: "file://" + filepath.Join(parent, "remote.git")}
+	r.git(parent, "init", "--bare", "remote.git")
+	r.git(parent, "init", "work")
+	r.git(r.dir, "checkout", "-b", "master")
+	r.git(r.dir, "remote", "add", "origin", r.remote)
+	rm := func() {
+		require.NoError(t, os.RemoveAll(parent))
+	}
+	return r, rm
+}
+
+func (r *gitRepo) git(dir string, args ...string) string {
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	cmd := exec.Command("git", args...) //nolint:gosec
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(r.t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+// commit commits to the given branch, pushes it and returns the commit.
+func (r *gitRepo) commit(branch, msg string) string {
+	r.git(r.dir, "checkout", "-B", branch)
+	r.git(r.dir, "commit", "--allow-empty", "-m", msg)
+	r.git(r.dir, "push", "-f", "origin", branch)
+	return r.git(r.dir, "rev-parse", "HEAD")
+}
+
+// tag creates an annotated tag, pushes it and returns the tagged commit.
+func (r *gitRepo) tag(name string) string {
+	r.git(r.dir, "tag", "-a", name, "-m", name)
+	r.git(r.dir, "push", "origin", name)
+	return r.git(r.dir, "rev-parse", "HEAD")
+}
+
+func TestGitRefChecker_Check(t *testing.T) {
+	repo, rmRepo := prepareGitRepo(t)
+	defer rmRepo()
+	db, rmDB := prepareDB(t)
+	defer rmDB()
+	ctx := context.Background()
+
+	t.Run("branch", func(t *testing.T) {
+		c1 := repo.commit("master", "first")
+		c := ServiceConfig{MainBranch: "master", Checker: CheckerConfig{Type: GitRefCheckerType, Remote: repo.remote}}
+		gc := NewGitRefChecker(db, "branch", c)
+
+		// The first seen commit is the baseline.
+		r, err := gc.Check(ctx)
+		require.NoError(t, err)
+		assert.False(t, r.HasUpdate)
+		assert.Equal(t, c1, r.Version)
+		assert.Equal(t, c1, db.ServiceLastUpdate("branch").Commit)
+
+		// Commits to other branches are ignored.
+		repo.commit("develop", "other")
+		r, err = gc.Check(ctx)
+		require.NoError(t, err)
+		assert.False(t, r.HasUpdate)
+
+		// The update is reported until the service is updated to it.
+		c2 := repo.commit("master", "second")
+		for i := 0; i < 2; i++ {
+			r, err = gc.Check(ctx)
+			require.NoError(t, err)
+			assert.True(t, r.HasUpdate)
+			assert.Equal(t, c2, r.Version)
+			assert.Equal(t, c1, db.ServiceLastUpdate("branch").Commit)
+		}
+		require.NoError(t, db.SetServiceLastUpdate("branch", store.Update{Tag: c2, Commit: c1}))
+		r, err = gc.Check(ctx)
+		require.NoError(t, err)
+		assert.False(t, r.HasUpdate)
+		assert.Equal(t, c2, db.ServiceLastUpdate("branch").Commit)
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		repo.commit("master", "v0.1.0")
+		repo.tag("v0.1.0")
+		repo.commit("master", "v0.2.0")
+		c2 := repo.tag("v0.2.0")
+		repo.commit("master", "v1.0.0-rc.1")
+		repo.tag("v1.0.0-rc.1")
+
+		c := ServiceConfig{Repo: repo.remote, Checker: CheckerConfig{Type: GitRefCheckerType, Tags: "v0.*"}}
+		require.NoError(t, processGitRefCheckerConfig(&c, &ServiceDefaultsConfig{MainBranch: "master"}))
+		require.NoError(t, db.SetServiceLastUpdate("tags", store.Update{Tag: "v0.1.0", Commit: "0000"}))
+
+		r, err := NewGitRefChecker(db, "tags", c).Check(ctx)
+		require.NoError(t, err)
+		assert.True(t, r.HasUpdate)
+		assert.Equal(t, "v0.2.0", r.Version)
+
+		require.NoError(t, db.SetServiceLastUpdate("tags", store.Update{Tag: "v0.2.0", Commit: "0000"}))
+		r, err = NewGitRefChecker(db, "tags", c).Check(ctx)
+		require.NoError(t, err)
+		assert.False(t, r.HasUpdate)
+		assert.Equal(t, c2, db.ServiceLastUpdate("tags").Commit)
+	})
+
+	t.Run("missing branch", func(t *testing.T) {
+		c := ServiceConfig{MainBranch: "stable", Checker: CheckerConfig{Type: GitRefCheckerType, Remote: repo.remote}}
+		_, err := NewGitRefChecker(db, "missing", c).Check(ctx)
+		assert.Error(t, err)
+	})
+}
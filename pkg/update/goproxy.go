@@ -0,0 +1,302 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+
+	"github.com/skycoin/skywire-updater/pkg/store"
+)
+
+// DefaultGoProxy is the GOPROXY used if neither the config nor the environment
+// specifies one.
+const DefaultGoProxy = "https://proxy.golang.org,direct"
+
+// errGoProxyNotFound occurs when a proxy does not have a module.
+var errGoProxyNotFound = errors.New("module not found")
+
+// goModuleInfo is the response of the '.info' and '@latest' endpoints of the
+// GOPROXY protocol.
+type goModuleInfo struct {
+	Version string    `json:"Version"`
+	Time    time.Time `json:"Time"`
+}
+
+// goProxy is an entry of a GOPROXY list.
+type goProxy struct {
+	url          string // URL of the proxy, "direct" or "off".
+	fallbackOnly bool   // Whether the next entry is only tried if the module is not found (separated by ',').
+}
+
+// GoProxyChecker checks for available updates of a Go module via the GOPROXY
+// protocol. The latest version is the highest release version listed by the
+// proxy, the version reported by '@latest' if none are listed, or the highest
+// release tag of the module's repository if it is looked up directly.
+type GoProxyChecker struct {
+	srvName string
+	c       ServiceConfig
+	proxies []goProxy
+	db      store.Store
+	log     *logging.Logger
+}
+
+// NewGoProxyChecker creates a new GoProxyChecker.
+func NewGoProxyChecker(db store.Store, srvName string, c ServiceConfig) *GoProxyChecker {
+	setGoProxyDefaults(&c.Checker, c.Repo)
+	return &GoProxyChecker{
+		srvName: srvName,
+		c:       c,
+		proxies: parseGoProxy(c.Checker.GoProxy),
+		db:      db,
+		log:     logging.MustGetLogger("goproxy-checker." + srvName),
+	}
+}
+
+// Check checks for updates.
+func (gc *GoProxyChecker) Check(ctx context.Context) (*Release, error) {
+	info, err := gc.latest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	last := gc.db.ServiceLastUpdate(gc.srvName)
+	hasUpdate := isNewer(gc.log, info.Version, info.Time, last)
+	if hasUpdate && last.IsRolledBack(info.Version) {
+		gc.log.Infof("Release '%s' is not offered as it was rolled back from.", info.Version)
+		hasUpdate = false
+	}
+	return &Release{
+		HasUpdate:   hasUpdate,
+		Version:     info.Version,
+		Timestamp:   info.Time,
+		CheckerType: GoProxyCheckerType,
+	}, nil
+}
+
+// latest resolves the latest version of the module by trying the entries of
+// the GOPROXY list in order.
+func (gc *GoProxyChecker) latest(ctx context.Context) (*goModuleInfo, error) {
+	cc := gc.c.Checker
+	if matchGoPrefixPatterns(cc.GoNoProxy, cc.Module) {
+		progress(ctx, gc.log, "Module '%s' matches GONOPROXY, looking it up directly.", cc.Module)
+		return gc.direct(ctx)
+	}
+	var err error
+	for _, p := range gc.proxies {
+		var info *goModuleInfo
+		switch p.url {
+		case "off":
+			return nil, errors.New("module lookup disabled by GOPROXY=off")
+		case "direct":
+			info, err = gc.direct(ctx)
+		default:
+			info, err = gc.fromProxy(ctx, p.url)
+		}
+		if err == nil {
+			return info, nil
+		}
+		gc.log.WithError(err).Warnf("Failed to look up module '%s' via '%s'.", cc.Module, p.url)
+		if p.fallbackOnly && err != errGoProxyNotFound {
+			break
+		}
+	}
+	if err == nil {
+		err = errors.New("GOPROXY list is empty")
+	}
+	return nil, err
+}
+
+// fromProxy resolves the latest version of the module via a proxy.
+func (gc *GoProxyChecker) fromProxy(ctx context.Context, proxyURL string) (*goModuleInfo, error) {
+	base := strings.TrimSuffix(proxyURL, "/") + "/" + escapeModulePath(gc.c.Checker.Module)
+	raw, err := gc.fetch(ctx, base+"/@v/list")
+	if err != nil {
+		return nil, err
+	}
+	var latest *Version
+	var latestRaw string
+	for _, line := range strings.Split(string(raw), "\n") {
+		v, err := ParseVersion(line)
+		if err != nil || !strings.HasPrefix(strings.TrimSpace(line), "v") || v.IsPrerelease() {
+			continue
+		}
+		if latest == nil || v.Compare(*latest) > 0 {
+			latest, latestRaw = &v, strings.TrimSpace(line)
+		}
+	}
+	endpoint := base + "/@latest"
+	if latest != nil {
+		endpoint = base + "/@v/" + latestRaw + ".info"
+	}
+	if raw, err = gc.fetch(ctx, endpoint); err != nil {
+		return nil, err
+	}
+	var info goModuleInfo
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return nil, fmt.Errorf("unrecognised json body: %s", err)
+	}
+	if info.Version == "" {
+		return nil, fmt.Errorf("no version in response of '%s'", endpoint)
+	}
+	progress(ctx, gc.log, "Proxy '%s' reports version '%s' of '%s'.", proxyURL, info.Version, gc.c.Checker.Module)
+	return &info, nil
+}
+
+// direct resolves the latest version of the module from the release tags of
+// its repository. The time of the version is unknown.
+func (gc *GoProxyChecker) direct(ctx context.Context) (*goModuleInfo, error) {
+	refs, err := lsRemote(ctx, gc.c.Checker.Remote, "--tags", "v*")
+	if err != nil {
+		return nil, err
+	}
+	var latest *Version
+	var info goModuleInfo
+	for _, ref := range refs {
+		v, err := ParseVersion(ref.name)
+		if err != nil || !strings.HasPrefix(ref.name, "v") || v.IsPrerelease() {
+			continue
+		}
+		if latest == nil || v.Compare(*latest) > 0 {
+			latest, info.Version = &v, ref.name
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("no release tags found in '%s'", gc.c.Checker.Remote)
+	}
+	progress(ctx, gc.log, "Repository '%s' has version '%s'.", gc.c.Checker.Remote, info.Version)
+	return &info, nil
+}
+
+func (gc *GoProxyChecker) fetch(ctx context.Context, url string) ([]byte, error) {
+	gc.log.Infoln("Request URL:", url)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return ioutil.ReadAll(resp.Body)
+	case http.StatusNotFound, http.StatusGone:
+		return nil, errGoProxyNotFound
+	default:
+		return nil, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+}
+
+// parseGoProxy parses a GOPROXY list. Entries are separated by ',' (the next
+// entry is only tried if the module is not found) or '|' (the next entry is
+// tried on any error).
+func parseGoProxy(list string) []goProxy {
+	var proxies []goProxy
+	for list != "" {
+		i := strings.IndexAny(list, ",|")
+		entry, sep := list, byte(0)
+		if i >= 0 {
+			entry, sep, list = list[:i], list[i], list[i+1:]
+		} else {
+			list = ""
+		}
+		if entry = strings.TrimSpace(entry); entry != "" {
+			proxies = append(proxies, goProxy{url: entry, fallbackOnly: sep != '|'})
+		}
+	}
+	return proxies
+}
+
+// matchGoPrefixPatterns returns whether any of the comma-separated glob
+// patterns matches a prefix of the module path (as GONOPROXY and GONOSUMDB do).
+func matchGoPrefixPatterns(patterns, module string) bool {
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		n := strings.Count(pattern, "/") + 1
+		prefix := module
+		if parts := strings.SplitN(module, "/", n+1); len(parts) > n {
+			prefix = strings.Join(parts[:n], "/")
+		}
+		if ok, err := path.Match(pattern, prefix); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeModulePath escapes a module path for the GOPROXY protocol, in which
+// upper-case letters are replaced by '!' followed by the lower-case letter.
+func escapeModulePath(module string) string {
+	var b strings.Builder
+	for _, r := range module {
+		if unicode.IsUpper(r) {
+			b.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// modulePath derives the module path from the service's repo (which is on
+// github if it has no domain).
+func modulePath(repo string) string {
+	domain, repoPath := splitRepo(repo)
+	if domain == "" {
+		domain = "github.com"
+	}
+	return domain + "/" + repoPath
+}
+
+func setGoProxyDefaults(cc *CheckerConfig, repo string) {
+	if cc.Module == "" {
+		cc.Module = modulePath(repo)
+	}
+	if cc.GoProxy == "" {
+		cc.GoProxy = os.Getenv("GOPROXY")
+	}
+	if cc.GoProxy == "" {
+		cc.GoProxy = DefaultGoProxy
+	}
+	if cc.GoNoProxy == "" {
+		cc.GoNoProxy = os.Getenv("GONOPROXY")
+	}
+	if cc.GoNoProxy == "" {
+		cc.GoNoProxy = os.Getenv("GOPRIVATE")
+	}
+	if cc.Remote == "" {
+		cc.Remote = gitRemote(cc.Module)
+	}
+}
+
+// Checks for errors and fills unspecified fields with default values.
+func processGoProxyCheckerConfig(sc *ServiceConfig) error {
+	if sc.Checker.Module == "" && sc.Repo == "" {
+		return errors.New("checker.module or repo needs to be defined")
+	}
+	setGoProxyDefaults(&sc.Checker, sc.Repo)
+	for _, p := range parseGoProxy(sc.Checker.GoProxy) {
+		if p.url != "direct" && p.url != "off" && !strings.HasPrefix(p.url, "http://") && !strings.HasPrefix(p.url, "https://") {
+			return fmt.Errorf("checker.goproxy entry '%s' is invalid", p.url)
+		}
+	}
+	for _, pattern := range strings.Split(sc.Checker.GoNoProxy, ",") {
+		if _, err := path.Match(strings.TrimSpace(pattern), ""); err != nil {
+			return fmt.Errorf("checker.gonoproxy is invalid: %s", err)
+		}
+	}
+	return nil
+}
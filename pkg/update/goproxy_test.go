@@ -0,0 +1,135 @@
+package update
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-updater/pkg/store"
+)
+
+func TestGoProxyChecker_Check(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/github.com/skycoin/skywire/@v/list", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("v0.1.0\nv0.2.0\nv0.10.0\nv0.11.0-rc.1\n")) //nolint:errcheck
+	})
+	mux.HandleFunc("/github.com/skycoin/skywire/@v/v0.10.0.info", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"Version": "v0.10.0", "Time": "2019-04-01T10:00:00Z"}`)) //nolint:errcheck
+	})
+	mux.HandleFunc("/github.com/!skycoin/dmsg/@v/list", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/github.com/!skycoin/dmsg/@latest", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"Version": "v0.0.0-20190402100000-abcdef123456", "Time": "2019-04-02T10:00:00Z"}`)) //nolint:errcheck
+	})
+	proxy := httptest.NewServer(mux)
+	defer proxy.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+	empty := httptest.NewServer(http.NotFoundHandler())
+	defer empty.Close()
+
+	db, rmDB := prepareDB(t)
+	defer rmDB()
+	require.NoError(t, db.SetServiceLastUpdate("srv", store.Update{Tag: "v0.2.0"}))
+	ctx := context.Background()
+
+	check := func(c ServiceConfig) (*Release, error) {
+		c.Checker.Type = GoProxyCheckerType
+		if c.Checker.GoNoProxy == "" {
+			c.Checker.GoNoProxy = "none.example.com"
+		}
+		return NewGoProxyChecker(db, "srv", c).Check(ctx)
+	}
+
+	t.Run("list", func(t *testing.T) {
+		r, err := check(ServiceConfig{Repo: "skycoin/skywire", Checker: CheckerConfig{GoProxy: proxy.URL}})
+		require.NoError(t, err)
+		assert.True(t, r.HasUpdate)
+		assert.Equal(t, "v0.10.0", r.Version)
+		assert.Equal(t, time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC), r.Timestamp.UTC())
+	})
+
+	t.Run("latest", func(t *testing.T) {
+		r, err := check(ServiceConfig{Checker: CheckerConfig{Module: "github.com/Skycoin/dmsg", GoProxy: proxy.URL}})
+		require.NoError(t, err)
+		assert.Equal(t, "v0.0.0-20190402100000-abcdef123456", r.Version)
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		// Entries separated by ',' are only tried if the module is not found.
+		r, err := check(ServiceConfig{Repo: "skycoin/skywire", Checker: CheckerConfig{GoProxy: empty.URL + "," + proxy.URL}})
+		require.NoError(t, err)
+		assert.Equal(t, "v0.10.0", r.Version)
+		_, err = check(ServiceConfig{Repo: "skycoin/skywire", Checker: CheckerConfig{GoProxy: broken.URL + "," + proxy.URL}})
+		assert.Error(t, err)
+
+		// Entries separated by '|' are tried on any error.
+		r, err = check(ServiceConfig{Repo: "skycoin/skywire", Checker: CheckerConfig{GoProxy: broken.URL + "|" + proxy.URL}})
+		require.NoError(t, err)
+		assert.Equal(t, "v0.10.0", r.Version)
+	})
+
+	t.Run("off", func(t *testing.T) {
+		_, err := check(ServiceConfig{Repo: "skycoin/skywire", Checker: CheckerConfig{GoProxy: empty.URL + ",off," + proxy.URL}})
+		assert.Error(t, err)
+	})
+
+	t.Run("direct", func(t *testing.T) {
+		repo, rmRepo := prepareGitRepo(t)
+		defer rmRepo()
+		repo.commit("master", "first")
+		repo.tag("v0.2.0")
+		repo.commit("master", "second")
+		repo.tag("v0.3.0")
+		repo.commit("master", "third")
+		repo.tag("v0.4.0-rc.1")
+
+		cc := CheckerConfig{Module: "git.example.com/skycoin/skywire", GoProxy: proxy.URL, GoNoProxy: "*.example.com", Remote: repo.remote}
+		r, err := check(ServiceConfig{Checker: cc})
+		require.NoError(t, err)
+		assert.True(t, r.HasUpdate)
+		assert.Equal(t, "v0.3.0", r.Version)
+
+		cc.GoProxy, cc.GoNoProxy = empty.URL+",direct", "none.example.com"
+		r, err = check(ServiceConfig{Checker: cc})
+		require.NoError(t, err)
+		assert.Equal(t, "v0.3.0", r.Version)
+	})
+}
+
+func TestMatchGoPrefixPatterns(t *testing.T) {
+	cases := []struct {
+		patterns string
+		module   string
+		want     bool
+	}{
+		{"", "github.com/skycoin/skywire", false},
+		{"github.com/skycoin", "github.com/skycoin/skywire", true},
+		{"github.com/skycoin", "github.com/skycoinproject/skywire", false},
+		{"*.example.com,github.com/skycoin/*", "github.com/skycoin/skywire/pkg", true},
+		{"*.example.com", "git.example.com/skycoin/skywire", true},
+		{"*.example.com", "github.com/skycoin/skywire", false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, matchGoPrefixPatterns(tc.patterns, tc.module), tc.patterns+" "+tc.module)
+	}
+}
+
+func TestProcessGoProxyCheckerConfig(t *testing.T) {
+	sc := ServiceConfig{Repo: "github.com/skycoin/skywire", Checker: CheckerConfig{Type: GoProxyCheckerType, GoProxy: "https://goproxy.example.com|direct"}}
+	require.NoError(t, processGoProxyCheckerConfig(&sc))
+	assert.Equal(t, "github.com/skycoin/skywire", sc.Checker.Module)
+	assert.Equal(t, "https://github.com/skycoin/skywire", sc.Checker.Remote)
+	assert.Equal(t, []goProxy{{url: "https://goproxy.example.com", fallbackOnly: false}, {url: "direct", fallbackOnly: true}},
+		parseGoProxy(sc.Checker.GoProxy))
+	assert.Equal(t, "github.com/!skycoin/skywire", escapeModulePath("github.com/Skycoin/skywire"))
+
+	assert.Error(t, processGoProxyCheckerConfig(&ServiceConfig{Checker: CheckerConfig{Type: GoProxyCheckerType}}))
+	assert.Error(t, processGoProxyCheckerConfig(&ServiceConfig{Repo: sc.Repo, Checker: CheckerConfig{Type: GoProxyCheckerType, GoProxy: "goproxy.example.com"}}))
+}
@@ -0,0 +1,251 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+)
+
+// HealthCheckType determines how the health of a service is checked.
+type HealthCheckType string
+
+const (
+	// HTTPHealthCheck sends a GET request to a URL and expects a given status.
+	HTTPHealthCheck = HealthCheckType("http")
+
+	// TCPHealthCheck dials a TCP address.
+	TCPHealthCheck = HealthCheckType("tcp")
+
+	// ProcessHealthCheck checks that the service's main process is running.
+	ProcessHealthCheck = HealthCheckType("process")
+
+	// ScriptHealthCheck runs a script, which exits with 0 if the service is healthy.
+	ScriptHealthCheck = HealthCheckType("script")
+)
+
+var healthCheckTypes = []HealthCheckType{
+	HTTPHealthCheck,
+	TCPHealthCheck,
+	ProcessHealthCheck,
+	ScriptHealthCheck,
+}
+
+// Default health check values.
+const (
+	DefaultHealthRetries  = 5
+	DefaultHealthInterval = 2 * time.Second
+	DefaultHealthTimeout  = 5 * time.Second
+)
+
+// HealthError occurs when a service does not become healthy after an update.
+type HealthError struct {
+	Check      HealthCheckType
+	Attempts   int
+	Err        error // Failure of the last attempt.
+	RolledBack bool  // Whether the previous binaries were restored.
+}
+
+// Error implements error.
+func (e *HealthError) Error() string {
+	msg := fmt.Sprintf("%s health check failed after %d attempts: %s", e.Check, e.Attempts, e.Err)
+	if e.RolledBack {
+		msg += " (rolled back)"
+	}
+	return msg
+}
+
+// HealthCheckConfig configures how the health of a service is checked after
+// each update. Health checks are disabled if no type is specified.
+type HealthCheckConfig struct {
+	Type     HealthCheckType `yaml:"type,omitempty"`
+	Retries  *int            `yaml:"retries,omitempty"`  // Number of attempts after the first failed one (an explicit 0 disables retries).
+	Interval time.Duration   `yaml:"interval,omitempty"` // Delay between attempts.
+	Timeout  time.Duration   `yaml:"timeout,omitempty"`  // Timeout of each attempt.
+	Rollback bool            `yaml:"rollback,omitempty"` // Restore the previous binaries if the service is not healthy.
+
+	// http health check fields:
+	URL    string `yaml:"url,omitempty"`
+	Status int    `yaml:"status,omitempty"` // Expected status code (200 if unspecified).
+
+	// tcp health check fields:
+	Addr string `yaml:"addr,omitempty"`
+
+	// script health check fields:
+	Interpreter string   `yaml:"interpreter,omitempty"`
+	Script      string   `yaml:"script,omitempty"`
+	Args        []string `yaml:"args,omitempty"`
+	Envs        []string `yaml:"envs,omitempty"`
+}
+
+// Enabled returns whether the health of the service is checked.
+func (h HealthCheckConfig) Enabled() bool {
+	return h.Type != ""
+}
+
+// retries returns the number of attempts after the first failed one.
+func (h HealthCheckConfig) retries() int {
+	if h.Retries == nil {
+		return DefaultHealthRetries
+	}
+	return *h.Retries
+}
+
+// HealthChecker checks the health of a service.
+type HealthChecker struct {
+	c   ServiceConfig
+	d   *ServiceDefaultsConfig
+	log *logging.Logger
+}
+
+// NewHealthChecker creates a new HealthChecker.
+func NewHealthChecker(srvName string, c ServiceConfig, d *ServiceDefaultsConfig) *HealthChecker {
+	return &HealthChecker{
+		c:   c,
+		d:   d,
+		log: logging.MustGetLogger("health-check." + srvName),
+	}
+}
+
+// Wait checks the health of the service until it is healthy, or the retries
+// are exhausted. The service's new version is given to health check scripts.
+func (hc *HealthChecker) Wait(ctx context.Context, version string) error {
+	h := hc.c.HealthCheck
+	attempts := h.retries() + 1
+	var err error
+	for i := 1; i <= attempts; i++ {
+		if err = hc.probe(ctx, version); err == nil {
+			progress(ctx, hc.log, "Service is healthy (attempt %d/%d).", i, attempts)
+			return nil
+		}
+		progress(ctx, hc.log, "Health check attempt %d/%d failed: %s", i, attempts, err)
+		if i == attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			// Cancellation counts as a failure, as the new version is live.
+			return &HealthError{Check: h.Type, Attempts: i, Err: ctx.Err()}
+		case <-time.After(h.Interval):
+		}
+	}
+	return &HealthError{Check: h.Type, Attempts: attempts, Err: err}
+}
+
+// probe makes a single health check attempt.
+func (hc *HealthChecker) probe(ctx context.Context, version string) error {
+	h := hc.c.HealthCheck
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	switch h.Type {
+	case HTTPHealthCheck:
+		req, err := http.NewRequest(http.MethodGet, h.URL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		if err := resp.Body.Close(); err != nil {
+			hc.log.WithError(err).Warn("Failed to close response body.")
+		}
+		if resp.StatusCode != h.Status {
+			return fmt.Errorf("expected status %d, got %d", h.Status, resp.StatusCode)
+		}
+		return nil
+
+	case TCPHealthCheck:
+		conn, err := new(net.Dialer).DialContext(ctx, "tcp", h.Addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+
+	case ProcessHealthCheck:
+		pids, err := findProcesses(hc.c.MainProcess)
+		if err != nil {
+			return err
+		}
+		if len(pids) == 0 {
+			return fmt.Errorf("process '%s' is not running", hc.c.MainProcess)
+		}
+		return nil
+
+	case ScriptHealthCheck:
+		cmd := exec.Command(h.Interpreter, append([]string{h.Script}, h.Args...)...) //nolint:gosec
+		cmd.Env = HealthCheckEnvs(hc.d, &hc.c, version)
+		healthy, err := ExecuteScript(ctx, hc.log, cmd)
+		if err != nil {
+			return err
+		}
+		if !healthy {
+			return errors.New("script reported service is unhealthy")
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("invalid health check type '%s'", h.Type)
+	}
+}
+
+// Checks for errors and fills unspecified fields with default values.
+func processHealthCheckConfig(h *HealthCheckConfig, sc *ServiceConfig, scriptsPath string, d *ServiceDefaultsConfig) error {
+	if !h.Enabled() {
+		return nil
+	}
+	if h.Retries == nil {
+		retries := DefaultHealthRetries
+		h.Retries = &retries
+	}
+	if h.Interval == 0 {
+		h.Interval = DefaultHealthInterval
+	}
+	if h.Timeout == 0 {
+		h.Timeout = DefaultHealthTimeout
+	}
+	if *h.Retries < 0 || h.Interval < 0 || h.Timeout < 0 {
+		return errors.New("health-check.retries, interval and timeout cannot be negative")
+	}
+	switch h.Type {
+	case HTTPHealthCheck:
+		if h.URL == "" {
+			return errors.New("health-check.url needs to be defined")
+		}
+		if h.Status == 0 {
+			h.Status = http.StatusOK
+		}
+	case TCPHealthCheck:
+		if h.Addr == "" {
+			return errors.New("health-check.addr needs to be defined")
+		}
+	case ProcessHealthCheck:
+		if sc.MainProcess == "" {
+			return errors.New("main-process needs to be defined for process health checks")
+		}
+	case ScriptHealthCheck:
+		if h.Interpreter == "" {
+			h.Interpreter = d.Interpreter
+		}
+		if h.Script == "" {
+			return errors.New("health-check.script needs to be defined")
+		}
+		if scriptsPath != "" {
+			h.Script = filepath.Join(scriptsPath, h.Script)
+		}
+		if _, err := os.Stat(h.Script); err != nil {
+			return fmt.Errorf("health-check.script cannot be accessed: %s", err.Error())
+		}
+	default:
+		return fmt.Errorf("invalid health-check.type '%s' when expecting: %v", h.Type, healthCheckTypes)
+	}
+	return nil
+}
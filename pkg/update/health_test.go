@@ -0,0 +1,146 @@
+package update
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthChecker_Wait(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	healthy, rmHealthy := prepareScript(t, `[[ "${SWU_TO_VERSION}" == "v2" ]]`)
+	defer rmHealthy()
+
+	cases := []struct {
+		name        string
+		mainProcess string
+		health      HealthCheckConfig
+		healthy     bool
+	}{
+		{"http", "", HealthCheckConfig{Type: HTTPHealthCheck, URL: srv.URL + "/health", Status: http.StatusOK}, true},
+		{"http unexpected status", "", HealthCheckConfig{Type: HTTPHealthCheck, URL: srv.URL + "/other", Status: http.StatusOK}, false},
+		{"tcp", "", HealthCheckConfig{Type: TCPHealthCheck, Addr: srv.Listener.Addr().String()}, true},
+		{"tcp refused", "", HealthCheckConfig{Type: TCPHealthCheck, Addr: closedAddr}, false},
+		{"process", filepath.Base(os.Args[0]), HealthCheckConfig{Type: ProcessHealthCheck}, true},
+		{"process not running", "skywire-updater-nonexistent", HealthCheckConfig{Type: ProcessHealthCheck}, false},
+		{"script", "", HealthCheckConfig{Type: ScriptHealthCheck, Interpreter: "/bin/bash", Script: healthy}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.health.Retries = intPtr(2)
+			tc.health.Interval = 10 * time.Millisecond
+			tc.health.Timeout = time.Second
+			c := ServiceConfig{MainProcess: tc.mainProcess, HealthCheck: tc.health}
+
+			err := NewHealthChecker("srv", c, new(ServiceDefaultsConfig)).Wait(context.Background(), "v2")
+			if tc.healthy {
+				assert.NoError(t, err)
+				return
+			}
+			hErr, ok := err.(*HealthError)
+			require.True(t, ok, err)
+			assert.Equal(t, 3, hErr.Attempts)
+			assert.Equal(t, tc.health.Type, hErr.Check)
+		})
+	}
+}
+
+func TestProcessHealthCheckConfig(t *testing.T) {
+	h := HealthCheckConfig{Type: TCPHealthCheck, Addr: "127.0.0.1:8080"}
+	require.NoError(t, processHealthCheckConfig(&h, &ServiceConfig{}, "", &ServiceDefaultsConfig{}))
+	require.NotNil(t, h.Retries)
+	assert.Equal(t, DefaultHealthRetries, *h.Retries)
+
+	// An explicit 0 disables retries.
+	h = HealthCheckConfig{Type: TCPHealthCheck, Addr: "127.0.0.1:8080", Retries: intPtr(0)}
+	require.NoError(t, processHealthCheckConfig(&h, &ServiceConfig{}, "", &ServiceDefaultsConfig{}))
+	assert.Equal(t, 0, *h.Retries)
+
+	h = HealthCheckConfig{Type: TCPHealthCheck, Addr: "127.0.0.1:8080", Retries: intPtr(-1)}
+	assert.Error(t, processHealthCheckConfig(&h, &ServiceConfig{}, "", &ServiceDefaultsConfig{}))
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestManager_install_unhealthy(t *testing.T) {
+	binDir, rmBin := prepareBinDir(t, map[string]string{"run": "v1"})
+	defer rmBin()
+	update, rmUpdate := prepareScript(t, `echo -n "${SWU_TO_VERSION}" > "${SWU_BIN_DIR}/run"`)
+	defer rmUpdate()
+	health, rmHealth := prepareScript(t, `v="$(cat "${SWU_BIN_DIR}/run")"
+[[ "${v}" != "v4" ]] || sleep 5
+[[ "${v}" != "v3" ]]`)
+	defer rmHealth()
+	db, rmDB := prepareDB(t)
+	defer rmDB()
+
+	conf := NewConfig(os.TempDir(), os.TempDir())
+	conf.Services.Services["srv"] = &ServiceConfig{
+		BinDir:        binDir,
+		CheckInterval: -1,
+		HealthCheck: HealthCheckConfig{
+			Type:        ScriptHealthCheck,
+			Interpreter: "/bin/bash",
+			Script:      health,
+			Retries:     intPtr(1),
+			Interval:    10 * time.Millisecond,
+			Timeout:     time.Second,
+			Rollback:    true,
+		},
+		Checker: CheckerConfig{Type: ScriptCheckerType},
+		Updater: UpdaterConfig{Type: ScriptUpdaterType, Interpreter: "/bin/bash", Script: update},
+	}
+	m := NewManager(db, conf)
+	defer func() {
+		require.NoError(t, m.Close())
+	}()
+
+	updated, err := m.Update(context.Background(), "srv", "v2", UpdateOptions{})
+	require.NoError(t, err)
+	assert.True(t, updated)
+
+	// The unhealthy version is rolled back from.
+	updated, err = m.Update(context.Background(), "srv", "v3", UpdateOptions{})
+	assert.False(t, updated)
+	hErr, ok := err.(*HealthError)
+	require.True(t, ok, err)
+	assert.True(t, hErr.RolledBack)
+	assert.Equal(t, map[string]string{"run": "v2"}, readBins(t, binDir))
+
+	last := db.ServiceLastUpdate("srv")
+	assert.Equal(t, "v2", last.Tag)
+	assert.True(t, last.IsRolledBack("v3"))
+
+	// Cancellation during the health check also rolls back.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	updated, err = m.Update(ctx, "srv", "v4", UpdateOptions{})
+	assert.False(t, updated)
+	hErr, ok = err.(*HealthError)
+	require.True(t, ok, err)
+	assert.True(t, hErr.RolledBack)
+	assert.Equal(t, context.Canceled, hErr.Err)
+	assert.Equal(t, map[string]string{"run": "v2"}, readBins(t, binDir))
+	assert.True(t, db.ServiceLastUpdate("srv").IsRolledBack("v4"))
+}
@@ -0,0 +1,280 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrJobNotFound occurs when a job is not found.
+	ErrJobNotFound = errors.New("job of given id is not found")
+
+	// ErrJobNotRunning occurs when attempting to cancel a job that has already finished.
+	ErrJobNotRunning = errors.New("job is not running")
+
+	// ErrJobCancelled is the error of a job that has been cancelled.
+	ErrJobCancelled = errors.New("job was cancelled")
+)
+
+// DefaultMaxJobs is the number of jobs which are kept track of. The oldest
+// finished jobs are forgotten first.
+const DefaultMaxJobs = 100
+
+// JobStatus is the status of a job.
+type JobStatus string
+
+const (
+	// JobRunning is the status of a job that has not finished yet.
+	JobRunning = JobStatus("running")
+
+	// JobSucceeded is the status of a job that updated the service.
+	JobSucceeded = JobStatus("succeeded")
+
+	// JobFailed is the status of a job that did not update the service.
+	JobFailed = JobStatus("failed")
+
+	// JobCancelled is the status of a job that was cancelled.
+	JobCancelled = JobStatus("cancelled")
+)
+
+// JobPhase is the progress phase of a job.
+type JobPhase string
+
+const (
+	// PhaseQueued is the phase of a job which waits for other operations on the
+	// service to finish.
+	PhaseQueued = JobPhase("queued")
+
+	// PhaseUpdating is the phase of a job which runs the service's updater.
+	PhaseUpdating = JobPhase("updating")
+
+	// PhaseRestarting is the phase of a job which restarts the updated service.
+	PhaseRestarting = JobPhase("restarting")
+
+	// PhaseHealthCheck is the phase of a job which waits for the updated
+	// service to become healthy.
+	PhaseHealthCheck = JobPhase("health-check")
+
+	// PhaseFinished is the phase of a job which has finished.
+	PhaseFinished = JobPhase("finished")
+)
+
+// JobInfo is a snapshot of a job.
+type JobInfo struct {
+	ID        string       `json:"id"`
+	Service   string       `json:"service"`
+	ToVersion string       `json:"to_version,omitempty"`
+	Trigger   string       `json:"trigger"`
+	Status    JobStatus    `json:"status"`
+	Phase     JobPhase     `json:"phase"`
+	Progress  *Progress    `json:"progress,omitempty"` // Most recent progress reported by the updater.
+	Updated   bool         `json:"updated"`
+	Error     string       `json:"error,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	EndedAt   *time.Time   `json:"ended_at,omitempty"`
+	Output    []OutputLine `json:"output,omitempty"`
+}
+
+// Job is an update of a service which runs in the background.
+type Job struct {
+	id        string
+	srvName   string
+	toVersion string
+	trigger   string
+	created   time.Time
+	output    *Output
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	status   JobStatus
+	phase    JobPhase
+	progress *Progress
+	updated  bool
+	err      error
+	ended    time.Time
+	mu       sync.RWMutex
+}
+
+// newJob creates a job which captures the updater's output into the given run.
+// The job's ID and service are those of the run.
+func newJob(output *Output, toVersion, trigger string, cancel context.CancelFunc) *Job {
+	return &Job{
+		id:        output.id,
+		srvName:   output.srvName,
+		toVersion: toVersion,
+		trigger:   trigger,
+		created:   time.Now(),
+		output:    output,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		status:    JobRunning,
+		phase:     PhaseQueued,
+	}
+}
+
+// ID returns the job's ID.
+func (j *Job) ID() string { return j.id }
+
+// Done returns a channel which is closed once the job finishes.
+func (j *Job) Done() <-chan struct{} { return j.done }
+
+// Result returns whether the job updated the service, and why not otherwise.
+// It should only be called once the job is done.
+func (j *Job) Result() (bool, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.updated, j.err
+}
+
+// Info returns a snapshot of the job, with or without the full transcript of
+// the updater's output.
+func (j *Job) Info(withOutput bool) JobInfo {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	info := JobInfo{
+		ID:        j.id,
+		Service:   j.srvName,
+		ToVersion: j.toVersion,
+		Trigger:   j.trigger,
+		Status:    j.status,
+		Phase:     j.phase,
+		Updated:   j.updated,
+		CreatedAt: j.created,
+	}
+	if j.progress != nil {
+		p := *j.progress
+		info.Progress = &p
+	}
+	if j.err != nil {
+		info.Error = j.err.Error()
+	}
+	if !j.ended.IsZero() {
+		ended := j.ended
+		info.EndedAt = &ended
+	}
+	if withOutput {
+		lines, err := j.output.Transcript()
+		if err != nil {
+			log.WithError(err).WithField("job", j.id).Warn("Failed to read transcript.")
+			lines = j.output.Lines()
+		}
+		info.Output = lines
+	}
+	return info
+}
+
+func (j *Job) setPhase(phase JobPhase) {
+	j.mu.Lock()
+	j.phase = phase
+	j.mu.Unlock()
+}
+
+// setProgress records a progress event of the updater. Events without a
+// percentage keep the previous one.
+func (j *Job) setProgress(p Progress) {
+	j.mu.Lock()
+	if p.Percent == nil && j.progress != nil {
+		p.Percent = j.progress.Percent
+	}
+	j.progress = &p
+	j.mu.Unlock()
+}
+
+type jobKey struct{}
+
+// withJobProgress returns a context which makes ExecuteScriptResult record
+// progress events as the progress of the job.
+func withJobProgress(ctx context.Context, job *Job) context.Context {
+	return context.WithValue(ctx, jobKey{}, job)
+}
+
+func jobFromContext(ctx context.Context) *Job {
+	job, _ := ctx.Value(jobKey{}).(*Job)
+	return job
+}
+
+func (j *Job) finish(updated bool, err error, cancelled bool) {
+	j.mu.Lock()
+	j.updated, j.err, j.ended, j.phase = updated, err, time.Now(), PhaseFinished
+	switch {
+	case cancelled:
+		j.status = JobCancelled
+	case updated:
+		j.status = JobSucceeded
+	default:
+		j.status = JobFailed
+	}
+	j.mu.Unlock()
+	close(j.done)
+}
+
+func (j *Job) running() bool {
+	select {
+	case <-j.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// jobList keeps track of the most recent jobs.
+type jobList struct {
+	max   int
+	byID  map[string]*Job
+	order []*Job // Oldest first.
+	mu    sync.RWMutex
+}
+
+func newJobList(max int) *jobList {
+	return &jobList{
+		max:  max,
+		byID: make(map[string]*Job),
+	}
+}
+
+func (l *jobList) add(job *Job) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.byID[job.id] = job
+	l.order = append(l.order, job)
+
+	// Forget the oldest finished jobs.
+	for i := 0; len(l.order) > l.max && i < len(l.order); {
+		if old := l.order[i]; !old.running() {
+			delete(l.byID, old.id)
+			l.order = append(l.order[:i], l.order[i+1:]...)
+			continue
+		}
+		i++
+	}
+}
+
+func (l *jobList) get(id string) (*Job, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	job, ok := l.byID[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// list lists the jobs of the given service (or all jobs if srvName is empty),
+// newest first.
+func (l *jobList) list(srvName string) []*Job {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	out := make([]*Job, 0, len(l.order))
+	for i := len(l.order) - 1; i >= 0; i-- {
+		if job := l.order[i]; srvName == "" || job.srvName == srvName {
+			out = append(out, job)
+		}
+	}
+	return out
+}
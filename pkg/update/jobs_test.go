@@ -0,0 +1,119 @@
+package update
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func waitJob(t *testing.T, job *Job) {
+	select {
+	case <-job.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for job")
+	}
+}
+
+func TestManager_StartUpdate(t *testing.T) {
+	update, rmUpdate := prepareScript(t, `echo "updating to ${SWU_TO_VERSION}"; echo "oops" >&2`)
+	defer rmUpdate()
+	slow, rmSlow := prepareScript(t, `sleep 30`)
+	defer rmSlow()
+	db, rmDB := prepareDB(t)
+	defer rmDB()
+
+	conf := NewConfig(os.TempDir(), os.TempDir())
+	conf.Services.Services["fast"] = &ServiceConfig{
+		CheckInterval: -1,
+		Checker:       CheckerConfig{Type: ScriptCheckerType},
+		Updater:       UpdaterConfig{Type: ScriptUpdaterType, Interpreter: "/bin/bash", Script: update},
+	}
+	conf.Services.Services["slow"] = &ServiceConfig{
+		CheckInterval: -1,
+		Checker:       CheckerConfig{Type: ScriptCheckerType},
+		Updater:       UpdaterConfig{Type: ScriptUpdaterType, Interpreter: "/bin/bash", Script: slow},
+	}
+	m := NewManager(db, conf)
+	defer func() {
+		require.NoError(t, m.Close())
+	}()
+
+	t.Run("succeeded", func(t *testing.T) {
+		job, err := m.StartUpdate("fast", "v1.0", UpdateOptions{Trigger: "test"})
+		require.NoError(t, err)
+		waitJob(t, job)
+
+		info, err := m.Job(job.ID())
+		require.NoError(t, err)
+		assert.Equal(t, JobSucceeded, info.Status)
+		assert.Equal(t, PhaseFinished, info.Phase)
+		assert.True(t, info.Updated)
+		assert.NotNil(t, info.EndedAt)
+		require.Len(t, info.Output, 2)
+		texts := make(map[string]string)
+		for _, l := range info.Output {
+			texts[l.Text] = l.Source
+		}
+		assert.Equal(t, map[string]string{"updating to v1.0": "stdout", "oops": "stderr"}, texts)
+
+		jobs, err := m.Jobs("fast")
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		assert.Equal(t, job.ID(), jobs[0].ID)
+		assert.Empty(t, jobs[0].Output)
+
+		assert.Equal(t, ErrJobNotRunning, m.CancelJob(job.ID()))
+
+		run, err := m.Run(job.ID())
+		require.NoError(t, err)
+		assert.Equal(t, UpdateRun, run.Info().Kind)
+		assert.Equal(t, 2, run.Info().Lines)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		job, err := m.StartUpdate("slow", "v1.0", UpdateOptions{Trigger: "test"})
+		require.NoError(t, err)
+		require.NoError(t, m.CancelJob(job.ID()))
+		waitJob(t, job)
+
+		info, err := m.Job(job.ID())
+		require.NoError(t, err)
+		assert.Equal(t, JobCancelled, info.Status)
+		assert.False(t, info.Updated)
+
+		history, err := m.History("slow")
+		require.NoError(t, err)
+		for _, h := range history {
+			assert.NotEqual(t, "succeeded", string(h.Outcome))
+		}
+	})
+
+	_, err := m.Job("unknown")
+	assert.Equal(t, ErrJobNotFound, err)
+	_, err = m.Jobs("unknown")
+	assert.Equal(t, ErrServiceNotFound, err)
+
+	jobs, err := m.Jobs("")
+	require.NoError(t, err)
+	assert.Len(t, jobs, 2)
+}
+
+func TestJobList(t *testing.T) {
+	l := newJobList(2)
+	running := newJob(newOutput(newRunID(), "srv", UpdateRun, 1), "", "", func() {})
+	l.add(running)
+	for i := 0; i < 3; i++ {
+		job := newJob(newOutput(newRunID(), "srv", UpdateRun, 1), "", "", func() {})
+		job.finish(true, nil, false)
+		l.add(job)
+	}
+	jobs := l.list("")
+	require.Len(t, jobs, 2)
+	assert.Equal(t, running, jobs[1])
+
+	_, err := l.get(running.ID())
+	assert.NoError(t, err)
+}
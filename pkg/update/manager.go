@@ -3,6 +3,9 @@ package update
 import (
 	"context"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"sort"
 	"sync"
 	"time"
@@ -23,34 +26,135 @@ type srvEntry struct {
 	ServiceConfig
 	Checker
 	Updater
+	restarter *Restarter
+	health    *HealthChecker
+	version   *VersionDetector
 	sync.Mutex
 }
 
 // Manager manages checkers and updaters for services.
+// It also periodically checks services for updates in the background, and runs
+// updates as jobs.
 type Manager struct {
 	global   ServiceDefaultsConfig
-	services map[string]srvEntry
+	services map[string]*srvEntry
 	mu       sync.RWMutex
 	db       store.Store
+	checks   *scheduler
+	jobs     *jobList
+	runs     *runList
+
+	transcripts string // Directory of run transcripts (if any).
+
+	ctx    context.Context // Cancelled on Close.
+	cancel context.CancelFunc
+	wg     sync.WaitGroup // Running jobs.
 }
 
-// NewManager creates a new manager.
+// NewManager creates a new manager and starts the scheduled checks.
 func NewManager(db store.Store, conf *Config) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
 	d := &Manager{
 		global:   conf.Services.Defaults,
-		services: make(map[string]srvEntry),
+		services: make(map[string]*srvEntry),
 		db:       db,
+		jobs:     newJobList(DefaultMaxJobs),
+		runs:     newRunList(DefaultMaxCheckRuns, DefaultMaxUpdateRuns),
+		ctx:      ctx,
+		cancel:   cancel,
 	}
+	d.transcripts = prepareTranscripts(conf.Paths.TranscriptsPath)
+	d.loadTranscripts()
+	d.checks = newScheduler(d.check)
 	for name, srv := range conf.Services.Services {
-		d.services[name] = srvEntry{
+		d.services[name] = &srvEntry{
 			ServiceConfig: *srv,
 			Checker:       NewChecker(db, name, *srv, &d.global),
 			Updater:       NewUpdater(name, *srv, &d.global),
+			restarter:     NewRestarter(name, *srv),
+			health:        NewHealthChecker(name, *srv, &d.global),
+			version:       NewVersionDetector(name, *srv, &d.global),
+		}
+	}
+	for name, srv := range d.services {
+		interval := srv.CheckInterval
+		if interval == 0 {
+			interval = d.global.CheckInterval
 		}
+		d.checks.start(name, interval)
 	}
 	return d
 }
 
+// prepareTranscripts creates the transcripts directory. Transcripts are
+// disabled (and an empty string returned) if the directory cannot be prepared.
+func prepareTranscripts(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		log.WithError(err).Warn("Failed to create transcripts directory, transcripts are disabled.")
+		return ""
+	}
+	return dir
+}
+
+// loadTranscripts loads the runs of previous instances from the transcripts
+// directory, so that they can still be obtained. The oldest runs beyond the
+// maximum number of runs are forgotten as usual.
+func (d *Manager) loadTranscripts() {
+	if d.transcripts == "" {
+		return
+	}
+	names, err := filepath.Glob(filepath.Join(d.transcripts, "*.jsonl"))
+	if err != nil {
+		log.WithError(err).Warn("Failed to list old transcripts.")
+		return
+	}
+	runs := make([]*Output, 0, len(names))
+	for _, name := range names {
+		o, err := loadFileOutput(name, DefaultOutputRingSize)
+		if err != nil {
+			log.WithError(err).Warnf("Failed to load old transcript '%s'.", name)
+			continue
+		}
+		runs = append(runs, o)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].started.Before(runs[j].started)
+	})
+	for _, o := range runs {
+		d.runs.add(o)
+	}
+}
+
+// newRun starts capturing the output of a run of the given service's scripts.
+func (d *Manager) newRun(srvName string, kind RunKind) *Output {
+	id := newRunID()
+	var o *Output
+	if d.transcripts != "" {
+		var err error
+		if o, err = newFileOutput(d.transcripts, id, srvName, kind, DefaultOutputRingSize); err != nil {
+			log.WithError(err).WithField("run", id).Warn("Failed to create transcript, only recent output is kept.")
+		}
+	}
+	if o == nil {
+		o = newOutput(id, srvName, kind, DefaultOutputRingSize)
+	}
+	d.runs.add(o)
+	return o
+}
+
+func (d *Manager) entry(srvName string) (*srvEntry, error) {
+	d.mu.RLock()
+	srv, ok := d.services[srvName]
+	d.mu.RUnlock()
+	if !ok {
+		return nil, ErrServiceNotFound
+	}
+	return srv, nil
+}
+
 // Services lists the available services.
 func (d *Manager) Services() []string {
 	d.mu.RLock()
@@ -65,46 +169,598 @@ func (d *Manager) Services() []string {
 
 // Check checks for updates for a given service.
 func (d *Manager) Check(ctx context.Context, srvName string) (*Release, error) {
-	d.mu.RLock()
-	srv, ok := d.services[srvName]
-	d.mu.RUnlock()
-	if !ok {
-		return nil, ErrServiceNotFound
+	return d.check(ctx, srvName)
+}
+
+// check detects the installed version of the given service, runs its checker,
+// caches the result and applies the service's policy.
+func (d *Manager) check(ctx context.Context, srvName string) (*Release, error) {
+	srv, err := d.entry(srvName)
+	if err != nil {
+		return nil, err
 	}
+	run := d.newRun(srvName, CheckRun)
 	srv.Lock()
-	release, err := srv.Check(ctx)
+	current := d.recordVersion(WithOutput(ctx, run), srvName, srv)
+	release, err := srv.Check(WithOutput(ctx, run))
+	if release != nil {
+		release.CurrentVersion = current
+	}
 	srv.Unlock()
-	return release, err
+	run.end()
+	d.checks.record(srvName, run.id, release, err)
+	if err != nil {
+		return nil, err
+	}
+	d.applyPolicy(srvName, srv, release)
+	return release, nil
 }
 
-// Update updates given service to provided version.
-func (d *Manager) Update(ctx context.Context, srvName, toVersion string) (bool, error) {
-	d.mu.RLock()
-	srv, ok := d.services[srvName]
-	d.mu.RUnlock()
-	if !ok {
-		return false, ErrServiceNotFound
+// detectVersion detects the installed version of the service. It returns an
+// empty string if version detection is disabled or fails.
+func (d *Manager) detectVersion(ctx context.Context, srvName string, srv *srvEntry) string {
+	if !srv.VersionDetect.Enabled() {
+		return ""
+	}
+	version, err := srv.version.Detect(ctx)
+	if err != nil {
+		log.WithError(err).WithField("service", srvName).Warn("Failed to detect installed version.")
+		return ""
+	}
+	return version
+}
+
+// recordVersion detects the installed version of the service and records it
+// if it changed. It returns the installed version.
+func (d *Manager) recordVersion(ctx context.Context, srvName string, srv *srvEntry) string {
+	last := d.db.ServiceLastUpdate(srvName)
+	version := d.detectVersion(ctx, srvName, srv)
+	if version == "" || version == last.Version {
+		return last.Installed()
+	}
+	last.Version = version
+	if err := d.db.SetServiceLastUpdate(srvName, last); err != nil {
+		log.WithError(err).WithField("service", srvName).Error("Failed to record installed version.")
+	}
+	return version
+}
+
+// applyPolicy decides what to do with a checked release, acts on the decision
+// and records it. Updates are started as jobs of the manager (rather than of
+// the check's caller), and the decision records the job.
+func (d *Manager) applyPolicy(srvName string, srv *srvEntry, release *Release) {
+	decision := srv.Policy.Decide(release)
+	if decision.Action == UpdateAction {
+		open, next, err := srv.Maintenance.Open(time.Now())
+		if err == nil && !open {
+			decision.Action = DeferAction
+			decision.DeferredUntil = &next
+			decision.Reason = fmt.Sprintf("update available and policy is auto, but deferred until the next maintenance window at %s",
+				next.Format(time.RFC3339))
+		}
+	}
+	if decision.Action == UpdateAction {
+		job, err := d.StartUpdate(srvName, release.Version, UpdateOptions{Trigger: TriggerPolicy})
+		if err != nil {
+			decision.Error = err.Error()
+		} else {
+			decision.JobID = job.id
+			d.wg.Add(1)
+			go func() {
+				defer d.wg.Done()
+				d.recordPolicyJob(srvName, job)
+			}()
+		}
+	}
+	d.checks.recordDecision(srvName, decision)
+
+	l := log.WithField("service", srvName).
+		WithField("policy", decision.Mode).
+		WithField("action", decision.Action).
+		WithField("version", decision.Version)
+	if decision.Error != "" {
+		l.WithField("error", decision.Error).Warnf("Policy decision: %s.", decision.Reason)
+		return
+	}
+	l.Infof("Policy decision: %s.", decision.Reason)
+}
+
+// recordPolicyJob waits for the job of an update applied by a policy, and
+// records its failure in the policy decision.
+func (d *Manager) recordPolicyJob(srvName string, job *Job) {
+	<-job.Done()
+	updated, err := job.Result()
+	switch {
+	case err != nil:
+	case !updated:
+		err = errors.New("update failed")
+	default:
+		return
+	}
+	d.checks.recordJobError(srvName, job.id, err)
+	log.WithError(err).WithField("service", srvName).WithField("job", job.id).Warn("Update applied by policy failed.")
+}
+
+// LastCheck obtains the cached result of the most recent check of a given
+// service, without running its checker.
+func (d *Manager) LastCheck(srvName string) (*CheckStatus, error) {
+	if _, err := d.entry(srvName); err != nil {
+		return nil, err
+	}
+	status := d.checks.status(srvName)
+	return &status, nil
+}
+
+// TriggerPolicy is the trigger of updates applied automatically by a policy.
+const TriggerPolicy = "policy"
+
+// UpdateOptions are the options of an update.
+type UpdateOptions struct {
+	Force   bool   // Update even if outside of the service's maintenance windows.
+	Trigger string // What triggered the update (recorded in the update history).
+}
+
+// Update updates given service to provided version, and waits for the update
+// to finish. The update is cancelled if ctx is done before then.
+// See StartUpdate for the possible errors.
+func (d *Manager) Update(ctx context.Context, srvName, toVersion string, opts UpdateOptions) (bool, error) {
+	job, err := d.StartUpdate(srvName, toVersion, opts)
+	if err != nil {
+		return false, err
+	}
+	select {
+	case <-job.Done():
+	case <-ctx.Done():
+		job.cancel()
+		<-job.Done()
+	}
+	return job.Result()
+}
+
+// StartUpdate starts a job which updates given service to provided version.
+// Unless forced, the update is rejected outside of the service's maintenance
+// windows with a *WindowError. Each attempt is recorded in the update history.
+func (d *Manager) StartUpdate(srvName, toVersion string, opts UpdateOptions) (*Job, error) {
+	srv, err := d.entry(srvName)
+	if err != nil {
+		return nil, err
 	}
+	if err := allowUpdate(srv, toVersion, opts); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(d.ctx)
+	job := newJob(d.newRun(srvName, UpdateRun), toVersion, opts.Trigger, cancel)
+	d.jobs.add(job)
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer cancel()
+		updated, err := d.runUpdate(withJobProgress(WithOutput(ctx, job.output), job), srv, job)
+		job.output.end()
+		job.finish(updated, err, ctx.Err() != nil)
+	}()
+	return job, nil
+}
+
+// allowUpdate checks that the service's policy and (unless forced) its
+// maintenance windows allow it to be moved to the given version.
+func allowUpdate(srv *srvEntry, toVersion string, opts UpdateOptions) error {
+	if !srv.Policy.Allows(toVersion) {
+		return ErrServicePinned
+	}
+	if !opts.Force {
+		open, next, err := srv.Maintenance.Open(time.Now())
+		if err != nil {
+			return err
+		}
+		if !open {
+			return &WindowError{Next: next}
+		}
+	}
+	return nil
+}
+
+func (d *Manager) runUpdate(ctx context.Context, srv *srvEntry, job *Job) (bool, error) {
 	srv.Lock()
-	updated, err := srv.Update(ctx, toVersion)
-	srv.Unlock()
+	defer srv.Unlock()
+	if ctx.Err() != nil {
+		return false, ErrJobCancelled
+	}
+	job.setPhase(PhaseUpdating)
+
+	hist := store.HistoryEntry{
+		Service:     job.srvName,
+		FromVersion: d.recordVersion(ctx, job.srvName, srv),
+		ToVersion:   job.toVersion,
+		StartTime:   time.Now().UnixNano(),
+		Trigger:     job.trigger,
+	}
+	updated, err := d.install(ctx, srv, job, hist.FromVersion)
+
+	hist.EndTime = time.Now().UnixNano()
+	switch {
+	case err != nil:
+		hist.Outcome = store.OutcomeFailed
+		hist.Error = err.Error()
+	case !updated:
+		hist.Outcome = store.OutcomeFailed
+		hist.Error = "updater reported failure"
+	default:
+		hist.Outcome = store.OutcomeSucceeded
+	}
+	if err := d.db.AppendHistory(hist); err != nil {
+		log.WithError(err).WithField("service", job.srvName).Error("Failed to record update history.")
+	}
+
+	if isRolledBack(err) {
+		d.recordRolledBack(job.srvName, job.toVersion)
+	}
 	if err != nil {
 		return false, err
 	}
 	if updated {
-		entry := store.Update{
-			Tag:       toVersion,
-			Timestamp: time.Now().UnixNano(),
+		// Updating to a version explicitly allows it to be offered again.
+		entry := d.db.ServiceLastUpdate(job.srvName)
+		entry.Tag, entry.Timestamp = job.toVersion, hist.EndTime
+		entry.Version = d.detectVersion(ctx, job.srvName, srv)
+		entry.RolledBack = removeVersion(entry.RolledBack, job.toVersion)
+		if err := d.db.SetServiceLastUpdate(job.srvName, entry); err != nil {
+			log.WithError(err).WithField("service", job.srvName).Error("Failed to record last update.")
 		}
-		d.db.SetServiceLastUpdate(srvName, entry)
 	}
 	return updated, nil
 }
 
-// Close closes the manager.
+// recordRolledBack records that the given version of a service was rolled
+// back from, so that it is not offered by checkers again.
+func (d *Manager) recordRolledBack(srvName, version string) {
+	entry := d.db.ServiceLastUpdate(srvName)
+	if version == "" || entry.IsRolledBack(version) {
+		return
+	}
+	entry.RolledBack = append(entry.RolledBack, version)
+	if err := d.db.SetServiceLastUpdate(srvName, entry); err != nil {
+		log.WithError(err).WithField("service", srvName).Error("Failed to record last update.")
+	}
+}
+
+// install runs the service's updater. Binaries are staged by the updater and
+// swapped with the live binaries (which are backed up) once it succeeds. The
+// service is then restarted and its health checked, if configured, and the
+// previous binaries are restored on failure if rollback is enabled.
+func (d *Manager) install(ctx context.Context, srv *srvEntry, job *Job, fromVersion string) (bool, error) {
+	if srv.BinDir == "" {
+		updated, err := srv.Update(ctx, job.toVersion)
+		if err != nil || !updated {
+			return updated, err
+		}
+		return true, d.activate(ctx, srv, job)
+	}
+	swap := newBinSwap(job.srvName, srv.BinDir, srv.Backups)
+	defer swap.cleanup()
+	if err := swap.stage(); err != nil {
+		return false, fmt.Errorf("failed to prepare staging directory: %s", err)
+	}
+	updateCtx, verified := withVerifiedBins(withBinDir(ctx, swap.stageDir))
+	updated, err := srv.Update(updateCtx, job.toVersion)
+	if err != nil || !updated {
+		// The live binaries are untouched.
+		return updated, err
+	}
+	if srv.Trust.Enabled() {
+		if err := srv.Trust.verifyStaged(ctx, job.srvName, swap.stageDir, verified); err != nil {
+			return false, err
+		}
+	}
+	if err := swap.swap(ctx, fromVersion); err != nil {
+		return false, err
+	}
+	if err := d.activate(ctx, srv, job); err != nil {
+		var rolledBack *bool
+		switch e := err.(type) {
+		case *RestartError:
+			if srv.Restart.Rollback {
+				rolledBack = &e.RolledBack
+			}
+		case *HealthError:
+			if srv.HealthCheck.Rollback {
+				rolledBack = &e.RolledBack
+			}
+		}
+		if rolledBack == nil {
+			return false, err
+		}
+		// The job may have been cancelled during activation, in which case the
+		// previous version is still restored.
+		ctx, cancel := detachContext(ctx, srv.Restart.Timeout)
+		defer cancel()
+		if rErr := swap.restore(ctx); rErr != nil {
+			return false, fmt.Errorf("%s (rollback also failed: %s)", err, rErr)
+		}
+		*rolledBack = true
+		if srv.Restart.Enabled() {
+			if rErr := srv.restarter.Restart(ctx); rErr != nil {
+				log.WithError(rErr).WithField("service", job.srvName).Error("Failed to restart the previous version.")
+			}
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// activate restarts the updated service and waits for it to become healthy, as
+// configured.
+func (d *Manager) activate(ctx context.Context, srv *srvEntry, job *Job) error {
+	if srv.Restart.Enabled() {
+		job.setPhase(PhaseRestarting)
+		if err := srv.restarter.Restart(ctx); err != nil {
+			return &RestartError{Err: err}
+		}
+	}
+	if srv.HealthCheck.Enabled() {
+		job.setPhase(PhaseHealthCheck)
+		return srv.health.Wait(ctx, job.toVersion)
+	}
+	return nil
+}
+
+// detachContext returns a context which is not cancelled along with the given
+// context (but times out after the given duration, if any), and which keeps
+// capturing output into the same run.
+func detachContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	detached := context.Background()
+	if o := outputFromContext(ctx); o != nil {
+		detached = WithOutput(detached, o)
+	}
+	if timeout <= 0 {
+		return context.WithCancel(detached)
+	}
+	return context.WithTimeout(detached, timeout)
+}
+
+func isRolledBack(err error) bool {
+	switch e := err.(type) {
+	case *RestartError:
+		return e.RolledBack
+	case *HealthError:
+		return e.RolledBack
+	default:
+		return false
+	}
+}
+
+// Rollback restores the binaries of a previous version of the given service from
+// its backups (the most recent suitable backup if toVersion is empty), and
+// returns the restored version. The version which is rolled back from is recorded, so that
+// it is not offered by checkers again. The service is then restarted (if
+// configured), and a *RestartError is returned along with the restored version
+// if it cannot be restarted. As with StartUpdate, ErrServicePinned is returned
+// if the service is pinned to another version, and (unless forced) a
+// *WindowError outside of the service's maintenance windows.
+func (d *Manager) Rollback(ctx context.Context, srvName, toVersion string, opts UpdateOptions) (string, error) {
+	srv, err := d.entry(srvName)
+	if err != nil {
+		return "", err
+	}
+	srv.Lock()
+	defer srv.Unlock()
+
+	from := d.recordVersion(ctx, srvName, srv)
+	last := d.db.ServiceLastUpdate(srvName)
+	backup, err := findBackup(srv.BinDir, srvName, toVersion, last)
+	if err != nil {
+		return "", err
+	}
+	if err := allowUpdate(srv, backup.Tag(), opts); err != nil {
+		return "", err
+	}
+	hist := store.HistoryEntry{
+		Service:     srvName,
+		FromVersion: from,
+		ToVersion:   backup.Tag(),
+		StartTime:   time.Now().UnixNano(),
+		Trigger:     opts.Trigger,
+		Rollback:    true,
+	}
+	err = d.restore(ctx, srvName, srv, backup, from)
+	restored := err == nil
+	if restored && srv.Restart.Enabled() {
+		if rErr := srv.restarter.Restart(ctx); rErr != nil {
+			err = &RestartError{Err: rErr}
+		}
+	}
+
+	hist.EndTime = time.Now().UnixNano()
+	hist.Outcome = store.OutcomeSucceeded
+	if err != nil {
+		hist.Outcome = store.OutcomeFailed
+		hist.Error = err.Error()
+	}
+	if err := d.db.AppendHistory(hist); err != nil {
+		log.WithError(err).WithField("service", srvName).Error("Failed to record update history.")
+	}
+	if !restored {
+		return "", err
+	}
+
+	if from != "" && !last.IsRolledBack(from) {
+		last.RolledBack = append(last.RolledBack, from)
+	}
+	last.RolledBack = removeVersion(last.RolledBack, backup.Tag())
+	last.Tag, last.Timestamp = backup.Tag(), hist.EndTime
+	last.Version = d.detectVersion(ctx, srvName, srv)
+	if err := d.db.SetServiceLastUpdate(srvName, last); err != nil {
+		log.WithError(err).WithField("service", srvName).Error("Failed to record last update.")
+	}
+	log.WithField("service", srvName).Infof("Rolled back from '%s' to '%s'.", hist.FromVersion, hist.ToVersion)
+	return backup.Tag(), err
+}
+
+// restore swaps the live binaries of the service with those of a backup. The
+// live binaries are backed up first.
+func (d *Manager) restore(ctx context.Context, srvName string, srv *srvEntry, backup *Backup, fromVersion string) error {
+	swap := newBinSwap(srvName, srv.BinDir, srv.Backups)
+	defer swap.cleanup()
+	if err := swap.stage(); err != nil {
+		return fmt.Errorf("failed to prepare staging directory: %s", err)
+	}
+	for _, name := range backup.Binaries {
+		if err := linkOrCopy(filepath.Join(backup.dir, name), filepath.Join(swap.stageDir, name)); err != nil {
+			return fmt.Errorf("failed to stage '%s': %s", name, err)
+		}
+	}
+	return swap.swap(ctx, fromVersion)
+}
+
+// Backups lists the backups of the given service, newest first.
+func (d *Manager) Backups(srvName string) ([]Backup, error) {
+	srv, err := d.entry(srvName)
+	if err != nil {
+		return nil, err
+	}
+	if srv.BinDir == "" {
+		return nil, nil
+	}
+	return listBackups(backupRoot(srv.BinDir, srvName))
+}
+
+func removeVersion(versions []string, version string) []string {
+	out := versions[:0]
+	for _, v := range versions {
+		if v != version {
+			out = append(out, v)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// Channel obtains the release channel of the given service, which is the
+// channel switched to via SetChannel (if any), or the configured channel.
+func (d *Manager) Channel(srvName string) (*ChannelInfo, error) {
+	srv, err := d.entry(srvName)
+	if err != nil {
+		return nil, err
+	}
+	channel := srv.Channel
+	if last := d.db.ServiceLastUpdate(srvName); last.Channel != "" {
+		if _, ok := srv.channel(last.Channel); ok {
+			channel = last.Channel
+		}
+	}
+	if channel == "" {
+		channel = StableChannel
+	}
+	return &ChannelInfo{Channel: channel, Channels: srv.channelNames()}, nil
+}
+
+// SetChannel switches the given service to a release channel. The channel is
+// recorded in the store, and overrides the configured channel. It fails with
+// ErrChannelsNotSupported if the service's checker does not consider channels.
+func (d *Manager) SetChannel(srvName, channel string) error {
+	srv, err := d.entry(srvName)
+	if err != nil {
+		return err
+	}
+	if !supportsChannels(srv.ServiceConfig.Checker) {
+		return ErrChannelsNotSupported
+	}
+	if _, ok := srv.channel(channel); !ok {
+		return ErrChannelNotFound
+	}
+	srv.Lock()
+	defer srv.Unlock()
+
+	last := d.db.ServiceLastUpdate(srvName)
+	prev := last.Channel
+	last.Channel = channel
+	if err := d.db.SetServiceLastUpdate(srvName, last); err != nil {
+		return err
+	}
+	log.WithField("service", srvName).Infof("Switched release channel from '%s' to '%s'.", prev, channel)
+	return nil
+}
+
+// Job obtains a snapshot of the job of given ID, including its output.
+func (d *Manager) Job(id string) (*JobInfo, error) {
+	job, err := d.jobs.get(id)
+	if err != nil {
+		return nil, err
+	}
+	info := job.Info(true)
+	return &info, nil
+}
+
+// Jobs lists snapshots of the recent jobs of given service (or of all services
+// if srvName is empty), newest first. The output of the jobs is not included.
+func (d *Manager) Jobs(srvName string) ([]JobInfo, error) {
+	if srvName != "" {
+		if _, err := d.entry(srvName); err != nil {
+			return nil, err
+		}
+	}
+	jobs := d.jobs.list(srvName)
+	out := make([]JobInfo, len(jobs))
+	for i, job := range jobs {
+		out[i] = job.Info(false)
+	}
+	return out, nil
+}
+
+// CancelJob cancels the job of given ID. The process group of a running
+// updater script is terminated.
+func (d *Manager) CancelJob(id string) error {
+	job, err := d.jobs.get(id)
+	if err != nil {
+		return err
+	}
+	if !job.running() {
+		return ErrJobNotRunning
+	}
+	job.cancel()
+	return nil
+}
+
+// Run obtains the output of the run of given ID. Runs of updates share the
+// IDs of their jobs.
+func (d *Manager) Run(id string) (*Output, error) {
+	return d.runs.get(id)
+}
+
+// Runs lists snapshots of the recent runs of given service (or of all services
+// if srvName is empty), newest first.
+func (d *Manager) Runs(srvName string) ([]RunInfo, error) {
+	if srvName != "" {
+		if _, err := d.entry(srvName); err != nil {
+			return nil, err
+		}
+	}
+	runs := d.runs.list(srvName)
+	out := make([]RunInfo, len(runs))
+	for i, run := range runs {
+		out[i] = run.Info()
+	}
+	return out, nil
+}
+
+// History obtains the update attempts of a given service, oldest first.
+func (d *Manager) History(srvName string) ([]store.HistoryEntry, error) {
+	if _, err := d.entry(srvName); err != nil {
+		return nil, err
+	}
+	return d.db.ServiceHistory(srvName), nil
+}
+
+// Close stops the scheduled checks, cancels running jobs and closes the manager.
 func (d *Manager) Close() error {
+	d.checks.stop()
+	d.cancel()
+	d.wg.Wait()
 	d.mu.Lock()
-	d.services = make(map[string]srvEntry)
+	d.services = make(map[string]*srvEntry)
 	d.mu.Unlock()
 	return d.db.Close()
 }
@@ -0,0 +1,421 @@
+package update
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+	"gopkg.in/yaml.v2"
+
+	"github.com/skycoin/skywire-updater/pkg/store"
+)
+
+// ManifestFormat is the format of a release manifest.
+type ManifestFormat string
+
+const (
+	// JSONManifest is the format of JSON manifests.
+	JSONManifest = ManifestFormat("json")
+
+	// YAMLManifest is the format of YAML manifests.
+	YAMLManifest = ManifestFormat("yaml")
+)
+
+var manifestFormats = []ManifestFormat{
+	JSONManifest,
+	YAMLManifest,
+}
+
+// Default paths of the fields of release manifests.
+const (
+	DefaultManifestVersionField   = "version"
+	DefaultManifestPublishedField = "published_at"
+	DefaultManifestURLsField      = "urls"
+	DefaultManifestChecksumsField = "checksums"
+)
+
+// ManifestFieldsConfig configures the paths of the fields of a release manifest.
+// Paths are dot-separated keys (or indexes of lists), such as
+// 'releases.0.version', and may contain {os} and {arch} placeholders.
+type ManifestFieldsConfig struct {
+	Version   string `yaml:"version,omitempty"`      // Version of the release (required).
+	Published string `yaml:"published-at,omitempty"` // RFC 3339 time or unix timestamp of the release (optional).
+	URLs      string `yaml:"urls,omitempty"`         // A download URL, or a list or map of them (optional).
+	Checksums string `yaml:"checksums,omitempty"`    // A checksum, or a list or map of them matching 'urls' (optional).
+}
+
+// Artifact is a downloadable file of a release.
+type Artifact struct {
+	Name     string `json:"name"`
+	URL      string `json:"url,omitempty"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// manifestRelease is the release described by a manifest.
+type manifestRelease struct {
+	version   string
+	pubAt     time.Time
+	artifacts []Artifact
+}
+
+// ManifestChecker checks for available updates via a JSON or YAML release
+// manifest served over HTTP.
+type ManifestChecker struct {
+	srvName string
+	c       ServiceConfig
+	db      store.Store
+	log     *logging.Logger
+
+	etag   string           // ETag of the last fetched manifest.
+	cached *manifestRelease // Release of the last fetched manifest.
+	mu     sync.Mutex
+}
+
+// NewManifestChecker creates a new ManifestChecker.
+func NewManifestChecker(db store.Store, srvName string, c ServiceConfig) *ManifestChecker {
+	setManifestFieldDefaults(&c.Checker.Fields)
+	return &ManifestChecker{
+		srvName: srvName,
+		c:       c,
+		db:      db,
+		log:     logging.MustGetLogger("manifest-checker." + srvName),
+	}
+}
+
+// Check checks for updates. An update is available if the manifest's version
+// is higher than the installed version.
+func (mc *ManifestChecker) Check(ctx context.Context) (*Release, error) {
+	release, err := mc.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	last := mc.db.ServiceLastUpdate(mc.srvName)
+	hasUpdate := isNewer(mc.log, release.version, release.pubAt, last)
+	if hasUpdate && last.IsRolledBack(release.version) {
+		mc.log.Infof("Release '%s' is not offered as it was rolled back from.", release.version)
+		hasUpdate = false
+	}
+	return &Release{
+		HasUpdate:   hasUpdate,
+		Version:     release.version,
+		Timestamp:   release.pubAt,
+		CheckerType: HTTPManifestCheckerType,
+		Artifacts:   release.artifacts,
+	}, nil
+}
+
+// fetch fetches and parses the manifest. The previously parsed manifest is
+// reused if the server reports that it is not modified.
+func (mc *ManifestChecker) fetch(ctx context.Context) (*manifestRelease, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	cc := mc.c.Checker
+	mc.log.Infoln("Request URL:", cc.URL)
+	req, err := http.NewRequest(http.MethodGet, cc.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range cc.Headers {
+		req.Header.Set(key, os.ExpandEnv(value))
+	}
+	if mc.etag != "" && mc.cached != nil {
+		req.Header.Set("If-None-Match", mc.etag)
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		if mc.cached != nil {
+			progress(ctx, mc.log, "Manifest is not modified (ETag %s).", mc.etag)
+			return mc.cached, nil
+		}
+		fallthrough
+	default:
+		return nil, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := decodeManifest(raw, mc.format(resp))
+	if err != nil {
+		return nil, err
+	}
+	release, err := parseManifest(manifest, cc.Fields)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest: %s", err)
+	}
+	mc.etag, mc.cached = resp.Header.Get("ETag"), release
+	return release, nil
+}
+
+// format returns the format of the manifest, which is determined by the
+// response if unspecified.
+func (mc *ManifestChecker) format(resp *http.Response) ManifestFormat {
+	if f := mc.c.Checker.Format; f != "" {
+		return f
+	}
+	ext := path.Ext(resp.Request.URL.Path)
+	if strings.Contains(resp.Header.Get("Content-Type"), "yaml") || ext == ".yaml" || ext == ".yml" {
+		return YAMLManifest
+	}
+	return JSONManifest
+}
+
+// decodeManifest decodes a manifest into maps, lists and scalar values.
+// Numbers are decoded as json.Number, which keeps them as written, so that
+// unquoted versions such as 1.10 are not altered.
+func decodeManifest(raw []byte, format ManifestFormat) (interface{}, error) {
+	switch format {
+	case YAMLManifest:
+		var v yamlValue
+		if err := yaml.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("unrecognised yaml manifest: %s", err)
+		}
+		return v.v, nil
+	default:
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("unrecognised json manifest: %s", err)
+		}
+		return v, nil
+	}
+}
+
+// yamlValue is a value decoded from YAML, in the form of JSON values decoded
+// by decodeManifest: maps have string keys, and numbers are json.Number.
+type yamlValue struct {
+	v interface{}
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (y *yamlValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v interface{}
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	switch v.(type) {
+	case map[interface{}]interface{}:
+		var m map[interface{}]yamlValue
+		if err := unmarshal(&m); err != nil {
+			return err
+		}
+		out := make(map[string]interface{}, len(m))
+		for key, value := range m {
+			out[fmt.Sprint(key)] = value.v
+		}
+		y.v = out
+	case []interface{}:
+		var l []yamlValue
+		if err := unmarshal(&l); err != nil {
+			return err
+		}
+		out := make([]interface{}, len(l))
+		for i, value := range l {
+			out[i] = value.v
+		}
+		y.v = out
+	case int, int64, uint64, float64:
+		// Scalars decoded into strings are kept as written.
+		var s string
+		if err := unmarshal(&s); err != nil {
+			return err
+		}
+		y.v = json.Number(s)
+	default:
+		y.v = v
+	}
+	return nil
+}
+
+// lookupField obtains the value at the given path of a decoded manifest. It
+// returns nil if there is no such value.
+func lookupField(v interface{}, fieldPath string) interface{} {
+	fieldPath = strings.NewReplacer("{os}", runtime.GOOS, "{arch}", runtime.GOARCH).Replace(fieldPath)
+	for _, key := range strings.Split(fieldPath, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// parseManifest extracts the release from a decoded manifest.
+func parseManifest(manifest interface{}, fields ManifestFieldsConfig) (*manifestRelease, error) {
+	var release manifestRelease
+	// Unquoted versions such as 1.10 are decoded as numbers (as written).
+	var version string
+	switch v := lookupField(manifest, fields.Version).(type) {
+	case string:
+		version = v
+	case json.Number:
+		version = v.String()
+	}
+	if version == "" {
+		return nil, fmt.Errorf("field '%s' is not a version string", fields.Version)
+	}
+	release.version = version
+
+	switch pubAt := lookupField(manifest, fields.Published).(type) {
+	case nil:
+	case string:
+		t, err := time.Parse(time.RFC3339, pubAt)
+		if err != nil {
+			return nil, fmt.Errorf("field '%s' is not a valid time: %s", fields.Published, err)
+		}
+		release.pubAt = t
+	case json.Number:
+		secs, err := pubAt.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("field '%s' is not a valid unix timestamp: %s", fields.Published, err)
+		}
+		release.pubAt = time.Unix(int64(secs), 0)
+	default:
+		return nil, fmt.Errorf("field '%s' is not a time", fields.Published)
+	}
+
+	urls, err := stringValues(lookupField(manifest, fields.URLs), fields.URLs)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range urls {
+		release.artifacts = append(release.artifacts, Artifact{Name: u.key, URL: u.value})
+	}
+	checksums := lookupField(manifest, fields.Checksums)
+	if checksum, ok := checksums.(string); ok {
+		if len(release.artifacts) != 1 {
+			return nil, fmt.Errorf("field '%s' is a single checksum, but there are %d urls", fields.Checksums, len(release.artifacts))
+		}
+		release.artifacts[0].Checksum = checksum
+		return &release, nil
+	}
+	values, err := stringValues(checksums, fields.Checksums)
+	if err != nil {
+		return nil, err
+	}
+	if err := matchChecksums(release.artifacts, values); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// keyedString is a string value of a manifest field, along with its key (the
+// map key, or the index within a list).
+type keyedString struct {
+	key, value string
+	index      int // Index within a list (-1 if the value is not in a list).
+}
+
+// stringValues obtains the strings of a field which is a string, or a list or
+// map of strings. Strings of maps are sorted by key, and other strings are keyed
+// by their base names (the file names of URLs).
+func stringValues(v interface{}, field string) ([]keyedString, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []keyedString{{key: path.Base(v), value: v, index: -1}}, nil
+	case []interface{}:
+		out := make([]keyedString, len(v))
+		for i, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("field '%s.%d' is not a string", field, i)
+			}
+			out[i] = keyedString{key: path.Base(s), value: s, index: i}
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make([]keyedString, 0, len(v))
+		for key, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("field '%s.%s' is not a string", field, key)
+			}
+			out = append(out, keyedString{key: key, value: s, index: -1})
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].key < out[j].key })
+		return out, nil
+	default:
+		return nil, fmt.Errorf("field '%s' is not a string, list or map", field)
+	}
+}
+
+// matchChecksums assigns checksums to artifacts. Checksums of a list belong to
+// the artifacts of the same index, and checksums of a map belong to the
+// artifacts of the same name.
+func matchChecksums(artifacts []Artifact, checksums []keyedString) error {
+	for _, c := range checksums {
+		matched := false
+		for i := range artifacts {
+			if (c.index >= 0 && c.index == i) || (c.index < 0 && c.key == artifacts[i].Name) {
+				artifacts[i].Checksum, matched = c.value, true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("checksum '%s' does not match any url", c.key)
+		}
+	}
+	return nil
+}
+
+func setManifestFieldDefaults(f *ManifestFieldsConfig) {
+	if f.Version == "" {
+		f.Version = DefaultManifestVersionField
+	}
+	if f.Published == "" {
+		f.Published = DefaultManifestPublishedField
+	}
+	if f.URLs == "" {
+		f.URLs = DefaultManifestURLsField
+	}
+	if f.Checksums == "" {
+		f.Checksums = DefaultManifestChecksumsField
+	}
+}
+
+// Checks for errors and fills unspecified fields with default values.
+func processManifestCheckerConfig(cc *CheckerConfig) error {
+	if cc.URL == "" {
+		return errors.New("checker.url needs to be defined")
+	}
+	switch cc.Format {
+	case "", JSONManifest, YAMLManifest:
+	default:
+		return fmt.Errorf("invalid checker.format '%s' when expecting: %v", cc.Format, manifestFormats)
+	}
+	setManifestFieldDefaults(&cc.Fields)
+	return nil
+}
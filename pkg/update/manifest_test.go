@@ -0,0 +1,188 @@
+package update
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-updater/pkg/store"
+)
+
+func TestManifestChecker_Check(t *testing.T) {
+	const jsonManifest = `{
+	"version": "v1.2.0",
+	"published_at": "2019-04-01T10:00:00Z",
+	"urls": ["https://dl.example.com/node-linux-amd64.tar.gz", "https://dl.example.com/node-linux-arm64.tar.gz"],
+	"checksums": {"node-linux-arm64.tar.gz": "f00d", "node-linux-amd64.tar.gz": "beef"}
+}`
+	const yamlManifest = `
+releases:
+  - tag: "2019.04.02"
+    date: 1554199200
+    downloads:
+      ` + runtime.GOOS + `-` + runtime.GOARCH + `:
+        url: "https://dl.example.com/node.zip"
+        sha256: "cafe"
+`
+	mux := http.NewServeMux()
+	mux.HandleFunc("/manifest.json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(jsonManifest)) //nolint:errcheck
+	})
+	mux.HandleFunc("/private/manifest.yml", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(yamlManifest)) //nolint:errcheck
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	require.NoError(t, os.Setenv("SWU_TEST_MANIFEST_TOKEN", "secret"))
+	defer func() {
+		require.NoError(t, os.Unsetenv("SWU_TEST_MANIFEST_TOKEN"))
+	}()
+
+	db, rmDB := prepareDB(t)
+	defer rmDB()
+	require.NoError(t, db.SetServiceLastUpdate("old", store.Update{Tag: "v1.1.0"}))
+	require.NoError(t, db.SetServiceLastUpdate("same", store.Update{Tag: "v1.2.0"}))
+
+	t.Run("json", func(t *testing.T) {
+		for srvName, wantUpdate := range map[string]bool{"old": true, "same": false, "new": true} {
+			c := ServiceConfig{Checker: CheckerConfig{Type: HTTPManifestCheckerType, URL: srv.URL + "/manifest.json"}}
+			r, err := NewManifestChecker(db, srvName, c).Check(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, wantUpdate, r.HasUpdate, srvName)
+			assert.Equal(t, "v1.2.0", r.Version)
+			assert.Equal(t, time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC), r.Timestamp.UTC())
+			assert.Equal(t, []Artifact{
+				{Name: "node-linux-amd64.tar.gz", URL: "https://dl.example.com/node-linux-amd64.tar.gz", Checksum: "beef"},
+				{Name: "node-linux-arm64.tar.gz", URL: "https://dl.example.com/node-linux-arm64.tar.gz", Checksum: "f00d"},
+			}, r.Artifacts)
+		}
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		c := ServiceConfig{Checker: CheckerConfig{
+			Type:    HTTPManifestCheckerType,
+			URL:     srv.URL + "/private/manifest.yml",
+			Headers: map[string]string{"Authorization": "Bearer ${SWU_TEST_MANIFEST_TOKEN}"},
+			Fields: ManifestFieldsConfig{
+				Version:   "releases.0.tag",
+				Published: "releases.0.date",
+				URLs:      "releases.0.downloads.{os}-{arch}.url",
+				Checksums: "releases.0.downloads.{os}-{arch}.sha256",
+			},
+		}}
+		require.NoError(t, processManifestCheckerConfig(&c.Checker))
+		r, err := NewManifestChecker(db, "old", c).Check(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "2019.04.02", r.Version)
+		assert.Equal(t, int64(1554199200), r.Timestamp.Unix())
+		assert.Equal(t, []Artifact{{Name: "node.zip", URL: "https://dl.example.com/node.zip", Checksum: "cafe"}}, r.Artifacts)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		c := ServiceConfig{Checker: CheckerConfig{Type: HTTPManifestCheckerType, URL: srv.URL + "/private/manifest.yml"}}
+		_, err := NewManifestChecker(db, "old", c).Check(context.Background())
+		assert.Error(t, err)
+	})
+
+	t.Run("missing version", func(t *testing.T) {
+		c := ServiceConfig{Checker: CheckerConfig{
+			Type:   HTTPManifestCheckerType,
+			URL:    srv.URL + "/manifest.json",
+			Fields: ManifestFieldsConfig{Version: "release.version"},
+		}}
+		_, err := NewManifestChecker(db, "old", c).Check(context.Background())
+		assert.Error(t, err)
+	})
+}
+
+func TestParseManifest_numericVersion(t *testing.T) {
+	cases := []struct {
+		format ManifestFormat
+		raw    string
+		want   string
+	}{
+		{YAMLManifest, "version: 1.2", "1.2"},
+		{YAMLManifest, "version: 1.10", "1.10"},
+		{YAMLManifest, "version: 3", "3"},
+		{JSONManifest, `{"version": 1.5}`, "1.5"},
+		{JSONManifest, `{"version": 1.10}`, "1.10"},
+	}
+	for _, tc := range cases {
+		manifest, err := decodeManifest([]byte(tc.raw), tc.format)
+		require.NoError(t, err, tc.raw)
+		release, err := parseManifest(manifest, ManifestFieldsConfig{Version: "version"})
+		require.NoError(t, err, tc.raw)
+		assert.Equal(t, tc.want, release.version, tc.raw)
+	}
+
+	manifest, err := decodeManifest([]byte("version: true"), YAMLManifest)
+	require.NoError(t, err)
+	_, err = parseManifest(manifest, ManifestFieldsConfig{Version: "version"})
+	assert.Error(t, err)
+}
+
+func TestManifestChecker_etag(t *testing.T) {
+	var requests, fetches int
+	manifest := `{"version": "v1.0.0"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		etag := `"` + manifest + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fetches++
+		_, _ = w.Write([]byte(manifest)) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	db, rmDB := prepareDB(t)
+	defer rmDB()
+	require.NoError(t, db.SetServiceLastUpdate("srv", store.Update{Tag: "v0.9.0"}))
+
+	mc := NewManifestChecker(db, "srv", ServiceConfig{Checker: CheckerConfig{Type: HTTPManifestCheckerType, URL: srv.URL}})
+	for i := 0; i < 2; i++ {
+		r, err := mc.Check(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "v1.0.0", r.Version)
+		assert.True(t, r.HasUpdate)
+	}
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 1, fetches)
+
+	// Cached manifests are still compared with the installed version.
+	require.NoError(t, db.SetServiceLastUpdate("srv", store.Update{Tag: "v1.0.0"}))
+	r, err := mc.Check(context.Background())
+	require.NoError(t, err)
+	assert.False(t, r.HasUpdate)
+	assert.Equal(t, 1, fetches)
+
+	manifest = `{"version": "v1.1.0"}`
+	r, err = mc.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1.0", r.Version)
+	assert.True(t, r.HasUpdate)
+	assert.Equal(t, 2, fetches)
+}
+
+func TestProcessManifestCheckerConfig(t *testing.T) {
+	cc := CheckerConfig{Type: HTTPManifestCheckerType, URL: "https://example.com/manifest.json"}
+	require.NoError(t, processManifestCheckerConfig(&cc))
+	assert.Equal(t, DefaultManifestVersionField, cc.Fields.Version)
+
+	assert.Error(t, processManifestCheckerConfig(&CheckerConfig{Type: HTTPManifestCheckerType}))
+	assert.Error(t, processManifestCheckerConfig(&CheckerConfig{Type: HTTPManifestCheckerType, URL: cc.URL, Format: "toml"}))
+}
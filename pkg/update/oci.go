@@ -0,0 +1,440 @@
+package update
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+
+	"github.com/skycoin/skywire-updater/pkg/store"
+)
+
+// DefaultOCITag is the floating tag tracked by the oci-registry checker if
+// neither a tag nor a tag pattern is configured.
+const DefaultOCITag = "latest"
+
+// ociManifestTypes are the accepted media types of image manifests.
+var ociManifestTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// OCIRegistryChecker checks for available updates of a container image via the
+// OCI distribution API. It tracks the newest tag matching a pattern, or the
+// digest of a floating tag (such as "latest").
+//
+// When a floating tag is tracked, the digest which the service is known to be
+// at is recorded in the store, as the git-ref checker does with commits.
+type OCIRegistryChecker struct {
+	srvName string
+	c       ServiceConfig
+	reg     *ociRegistry
+	db      store.Store
+	log     *logging.Logger
+}
+
+// NewOCIRegistryChecker creates a new OCIRegistryChecker.
+func NewOCIRegistryChecker(db store.Store, srvName string, c ServiceConfig) *OCIRegistryChecker {
+	setOCIRegistryDefaults(&c.Checker)
+	log := logging.MustGetLogger("oci-registry-checker." + srvName)
+	return &OCIRegistryChecker{
+		srvName: srvName,
+		c:       c,
+		reg:     newOCIRegistry(log, c.Checker),
+		db:      db,
+		log:     log,
+	}
+}
+
+// Check checks for updates.
+func (oc *OCIRegistryChecker) Check(ctx context.Context) (*Release, error) {
+	if oc.c.Checker.Tag != "" {
+		return oc.checkDigest(ctx)
+	}
+	return oc.checkTags(ctx)
+}
+
+// checkTags checks whether the newest tag matching the tag pattern is newer
+// than the installed version.
+func (oc *OCIRegistryChecker) checkTags(ctx context.Context) (*Release, error) {
+	pattern := oc.c.Checker.Tags
+	tags, err := oc.reg.tags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var newest string
+	for _, tag := range tags {
+		if ok, err := path.Match(pattern, tag); err != nil || !ok {
+			continue
+		}
+		if newest == "" || newerTag(tag, newest) {
+			newest = tag
+		}
+	}
+	if newest == "" {
+		return nil, fmt.Errorf("no tag matching '%s' found in '%s'", pattern, oc.c.Checker.Image)
+	}
+	digest, err := oc.reg.digest(ctx, newest)
+	if err != nil {
+		return nil, err
+	}
+	progress(ctx, oc.log, "Tag '%s' is the newest matching '%s', at digest %s.", newest, pattern, digest)
+
+	last := oc.db.ServiceLastUpdate(oc.srvName)
+	hasUpdate := isNewer(oc.log, newest, time.Time{}, last)
+	if hasUpdate && last.IsRolledBack(newest) {
+		oc.log.Infof("Release '%s' is not offered as it was rolled back from.", newest)
+		hasUpdate = false
+	}
+	return &Release{
+		HasUpdate:   hasUpdate,
+		Version:     newest,
+		Timestamp:   time.Now(),
+		CheckerType: OCIRegistryCheckerType,
+		Digest:      digest,
+	}, nil
+}
+
+// checkDigest checks whether the floating tag points to another digest than
+// the one the service is known to be at.
+func (oc *OCIRegistryChecker) checkDigest(ctx context.Context) (*Release, error) {
+	tag := oc.c.Checker.Tag
+	digest, err := oc.reg.digest(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+	release := &Release{
+		Version:     digest,
+		Timestamp:   time.Now(),
+		CheckerType: OCIRegistryCheckerType,
+		Digest:      digest,
+	}
+	progress(ctx, oc.log, "Tag '%s' is at digest %s.", tag, digest)
+
+	last := oc.db.ServiceLastUpdate(oc.srvName)
+	switch {
+	case last.Digest == "" || last.Installed() == digest:
+		if last.Digest != digest {
+			last.Digest = digest
+			if err := oc.db.SetServiceLastUpdate(oc.srvName, last); err != nil {
+				oc.log.WithError(err).Error("Failed to record digest.")
+			}
+		}
+	case last.IsRolledBack(digest):
+		oc.log.Infof("Release '%s' is not offered as it was rolled back from.", digest)
+	default:
+		release.HasUpdate = digest != last.Digest
+	}
+	return release, nil
+}
+
+// ociRegistry is a client of the OCI distribution API of an image repository.
+// Credentials are only sent once the registry asks for them, either directly
+// (basic auth) or to the token service it refers to (token auth).
+type ociRegistry struct {
+	log      *logging.Logger
+	baseURL  string
+	repo     string
+	username string
+	password string
+
+	mu   sync.Mutex
+	auth string // Authorization header of requests, once authenticated.
+}
+
+func newOCIRegistry(l *logging.Logger, cc CheckerConfig) *ociRegistry {
+	baseURL, repo := parseImage(cc.Image)
+	if cc.BaseURL != "" {
+		baseURL = cc.BaseURL
+	}
+	return &ociRegistry{
+		log:      l,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		repo:     repo,
+		username: cc.Username,
+		password: cc.Password,
+	}
+}
+
+// tags lists all tags of the repository, following paginated responses.
+func (r *ociRegistry) tags(ctx context.Context) ([]string, error) {
+	var tags []string
+	u := r.baseURL + "/v2/" + r.repo + "/tags/list?n=1000"
+	for u != "" {
+		resp, err := r.do(ctx, http.MethodGet, u, "application/json")
+		if err != nil {
+			return nil, err
+		}
+		var body struct {
+			Tags []string `json:"tags"`
+		}
+		err = decodeOCIResponse(resp, &body)
+		link := resp.Header.Get("Link")
+		resp.Body.Close() //nolint:errcheck
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, body.Tags...)
+		if u, err = nextLink(u, link); err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+// digest obtains the digest of the manifest of the given tag. It is taken from
+// the Docker-Content-Digest header, or computed from the manifest if the
+// registry does not provide it.
+func (r *ociRegistry) digest(ctx context.Context, tag string) (string, error) {
+	u := r.baseURL + "/v2/" + r.repo + "/manifests/" + url.PathEscape(tag)
+	resp, err := r.do(ctx, http.MethodHead, u, ociManifestTypes...)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close() //nolint:errcheck
+	if digest := resp.Header.Get("Docker-Content-Digest"); resp.StatusCode == http.StatusOK && digest != "" {
+		return digest, nil
+	}
+
+	if resp, err = r.do(ctx, http.MethodGet, u, ociManifestTypes...); err != nil {
+		return "", err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected response status of manifest of tag '%s': %s", tag, resp.Status)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "", err
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// do sends a request, and authenticates and retries it if the registry asks
+// for authentication.
+func (r *ociRegistry) do(ctx context.Context, method, u string, accept ...string) (*http.Response, error) {
+	resp, err := r.send(ctx, method, u, accept)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close() //nolint:errcheck
+	if err := r.authenticate(ctx, challenge); err != nil {
+		return nil, err
+	}
+	return r.send(ctx, method, u, accept)
+}
+
+func (r *ociRegistry) send(ctx context.Context, method, u string, accept []string) (*http.Response, error) {
+	r.log.Infoln("Request URL:", u)
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, mediaType := range accept {
+		req.Header.Add("Accept", mediaType)
+	}
+	r.mu.Lock()
+	if r.auth != "" {
+		req.Header.Set("Authorization", r.auth)
+	}
+	r.mu.Unlock()
+	return http.DefaultClient.Do(req.WithContext(ctx))
+}
+
+// authenticate answers an authentication challenge of the registry.
+func (r *ociRegistry) authenticate(ctx context.Context, challenge string) error {
+	username, password := os.ExpandEnv(r.username), os.ExpandEnv(r.password)
+	scheme, params := parseAuthChallenge(challenge)
+	var auth string
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if username == "" {
+			return errors.New("registry requires credentials, checker.username needs to be defined")
+		}
+		auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+	case "bearer":
+		token, err := r.fetchToken(ctx, params, username, password)
+		if err != nil {
+			return err
+		}
+		auth = "Bearer " + token
+	default:
+		return fmt.Errorf("unsupported registry authentication challenge '%s'", challenge)
+	}
+	r.mu.Lock()
+	r.auth = auth
+	r.mu.Unlock()
+	return nil
+}
+
+// fetchToken obtains a pull token from the token service given by the
+// parameters of a bearer challenge.
+func (r *ociRegistry) fetchToken(ctx context.Context, params map[string]string, username, password string) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid realm '%s' of registry authentication challenge", params["realm"])
+	}
+	q := realm.Query()
+	if service := params["service"]; service != "" {
+		q.Set("service", service)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + r.repo + ":pull"
+	}
+	q.Set("scope", scope)
+	realm.RawQuery = q.Encode()
+
+	r.log.Infoln("Request URL:", realm)
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := decodeOCIResponse(resp, &body); err != nil {
+		return "", fmt.Errorf("failed to obtain registry token: %s", err)
+	}
+	if body.Token == "" {
+		body.Token = body.AccessToken
+	}
+	if body.Token == "" {
+		return "", errors.New("failed to obtain registry token: no token in response")
+	}
+	return body.Token, nil
+}
+
+func decodeOCIResponse(resp *http.Response, v interface{}) error {
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512)) //nolint:errcheck
+		return fmt.Errorf("unexpected response status: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("unrecognised json body: %s", err)
+	}
+	return nil
+}
+
+// parseAuthChallenge parses the scheme and parameters of a WWW-Authenticate
+// header, such as 'Bearer realm="https://auth.docker.io/token",service="registry.docker.io"'.
+func parseAuthChallenge(challenge string) (string, map[string]string) {
+	scheme, rest := strings.TrimSpace(challenge), ""
+	if i := strings.IndexByte(scheme, ' '); i >= 0 {
+		scheme, rest = scheme[:i], scheme[i+1:]
+	}
+	params := make(map[string]string)
+	for {
+		rest = strings.TrimLeft(rest, " ,")
+		i := strings.IndexByte(rest, '=')
+		if i < 0 {
+			return scheme, params
+		}
+		key, value := strings.ToLower(strings.TrimSpace(rest[:i])), rest[i+1:]
+		if strings.HasPrefix(value, `"`) {
+			value = value[1:]
+			if end := strings.IndexByte(value, '"'); end >= 0 {
+				value, rest = value[:end], value[end+1:]
+			} else {
+				rest = ""
+			}
+		} else if end := strings.IndexByte(value, ','); end >= 0 {
+			value, rest = value[:end], value[end+1:]
+		} else {
+			rest = ""
+		}
+		params[key] = value
+	}
+}
+
+// nextLink resolves the URL of the next page given by a Link header, which is
+// empty if there is none.
+func nextLink(current, link string) (string, error) {
+	for _, entry := range strings.Split(link, ",") {
+		parts := strings.Split(entry, ";")
+		if len(parts) < 2 || !strings.Contains(strings.Join(parts[1:], ";"), `rel="next"`) {
+			continue
+		}
+		base, err := url.Parse(current)
+		if err != nil {
+			return "", err
+		}
+		next, err := url.Parse(strings.Trim(strings.TrimSpace(parts[0]), "<>"))
+		if err != nil {
+			return "", fmt.Errorf("invalid Link header '%s': %s", link, err)
+		}
+		return base.ResolveReference(next).String(), nil
+	}
+	return "", nil
+}
+
+// parseImage splits an image reference into the API base URL of its registry
+// and its repository. Images without a registry are on docker hub, where
+// official images are in the 'library' namespace.
+func parseImage(image string) (string, string) {
+	registry, repo := "docker.io", image
+	if parts := strings.SplitN(image, "/", 2); len(parts) == 2 &&
+		(strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		registry, repo = parts[0], parts[1]
+	}
+	if registry == "docker.io" {
+		registry = "registry-1.docker.io"
+		if !strings.Contains(repo, "/") {
+			repo = "library/" + repo
+		}
+	}
+	return "https://" + registry, repo
+}
+
+func setOCIRegistryDefaults(cc *CheckerConfig) {
+	if cc.Tag == "" && cc.Tags == "" {
+		cc.Tag = DefaultOCITag
+	}
+}
+
+// Checks for errors and fills unspecified fields with default values.
+func processOCIRegistryCheckerConfig(cc *CheckerConfig) error {
+	if cc.Image == "" {
+		return errors.New("checker.image needs to be defined")
+	}
+	if _, repo := parseImage(cc.Image); strings.ContainsAny(path.Base(repo), ":@") {
+		return errors.New("checker.image should not contain a tag or digest, use checker.tag or checker.tags instead")
+	}
+	if cc.Tag != "" && cc.Tags != "" {
+		return errors.New("only one of checker.tag and checker.tags can be defined")
+	}
+	if _, err := path.Match(cc.Tags, ""); err != nil {
+		return fmt.Errorf("checker.tags is invalid: %s", err)
+	}
+	setOCIRegistryDefaults(cc)
+	return nil
+}
@@ -0,0 +1,199 @@
+package update
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-updater/pkg/store"
+)
+
+// testRegistry is an in-process stand-in of an OCI registry with token auth.
+type testRegistry struct {
+	*httptest.Server
+	mu      sync.Mutex
+	digests map[string]string // Digests of tags (manifests of other tags are served without digest header).
+	tokens  int               // Number of issued tokens.
+}
+
+func prepareRegistry(t *testing.T) *testRegistry {
+	reg := &testRegistry{digests: map[string]string{"latest": "sha256:aaaa"}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		usr, pwd, ok := r.BasicAuth()
+		if !ok || usr != "skycoin" || pwd != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "test-registry", r.URL.Query().Get("service"))
+		assert.Equal(t, "repository:skycoin/skywire:pull", r.URL.Query().Get("scope"))
+		reg.mu.Lock()
+		reg.tokens++
+		reg.mu.Unlock()
+		_, _ = w.Write([]byte(`{"token": "t0ken"}`)) //nolint:errcheck
+	})
+	mux.HandleFunc("/v2/skycoin/skywire/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("last") == "" {
+			w.Header().Set("Link", `</v2/skycoin/skywire/tags/list?n=3&last=v0.2.0>; rel="next"`)
+			_, _ = w.Write([]byte(`{"name": "skycoin/skywire", "tags": ["latest", "v0.1.0", "v0.2.0"]}`)) //nolint:errcheck
+			return
+		}
+		_, _ = w.Write([]byte(`{"name": "skycoin/skywire", "tags": ["v0.10.0", "v0.11.0-rc.1", "nightly"]}`)) //nolint:errcheck
+	})
+	mux.HandleFunc("/v2/skycoin/skywire/manifests/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.Header["Accept"], "application/vnd.oci.image.index.v1+json")
+		reg.mu.Lock()
+		digest := reg.digests[strings.TrimPrefix(r.URL.Path, "/v2/skycoin/skywire/manifests/")]
+		reg.mu.Unlock()
+		w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+		if digest != "" {
+			w.Header().Set("Docker-Content-Digest", digest)
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"schemaVersion": 2}`)) //nolint:errcheck
+		}
+	})
+	reg.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v2/") && r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.Header().Set("WWW-Authenticate",
+				`Bearer realm="`+reg.URL+`/token",service="test-registry",scope="repository:skycoin/skywire:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	return reg
+}
+
+func (reg *testRegistry) setDigest(tag, digest string) {
+	reg.mu.Lock()
+	reg.digests[tag] = digest
+	reg.mu.Unlock()
+}
+
+func TestOCIRegistryChecker_Check(t *testing.T) {
+	reg := prepareRegistry(t)
+	defer reg.Close()
+	db, rmDB := prepareDB(t)
+	defer rmDB()
+	ctx := context.Background()
+
+	require.NoError(t, os.Setenv("SWU_TEST_REGISTRY_PASSWORD", "secret"))
+	defer func() {
+		require.NoError(t, os.Unsetenv("SWU_TEST_REGISTRY_PASSWORD"))
+	}()
+	cc := CheckerConfig{
+		Type:     OCIRegistryCheckerType,
+		Image:    "registry.example.com/skycoin/skywire",
+		BaseURL:  reg.URL,
+		Username: "skycoin",
+		Password: "${SWU_TEST_REGISTRY_PASSWORD}",
+	}
+
+	t.Run("tags", func(t *testing.T) {
+		require.NoError(t, db.SetServiceLastUpdate("tags", store.Update{Tag: "v0.2.0"}))
+		cc := cc
+		cc.Tags = "v0.1[0-9].[0-9]"
+		r, err := NewOCIRegistryChecker(db, "tags", ServiceConfig{Checker: cc}).Check(ctx)
+		require.NoError(t, err)
+		assert.True(t, r.HasUpdate)
+		assert.Equal(t, "v0.10.0", r.Version)
+		sum := sha256.Sum256([]byte(`{"schemaVersion": 2}`))
+		assert.Equal(t, "sha256:"+hex.EncodeToString(sum[:]), r.Digest)
+
+		require.NoError(t, db.SetServiceLastUpdate("tags", store.Update{Tag: "v0.10.0"}))
+		r, err = NewOCIRegistryChecker(db, "tags", ServiceConfig{Checker: cc}).Check(ctx)
+		require.NoError(t, err)
+		assert.False(t, r.HasUpdate)
+
+		cc.Tags = "v1.*"
+		_, err = NewOCIRegistryChecker(db, "tags", ServiceConfig{Checker: cc}).Check(ctx)
+		assert.Error(t, err)
+	})
+
+	t.Run("digest", func(t *testing.T) {
+		oc := NewOCIRegistryChecker(db, "digest", ServiceConfig{Checker: cc})
+		tokens := reg.tokens
+
+		// The first seen digest is the baseline.
+		r, err := oc.Check(ctx)
+		require.NoError(t, err)
+		assert.False(t, r.HasUpdate)
+		assert.Equal(t, "sha256:aaaa", r.Version)
+		assert.Equal(t, "sha256:aaaa", db.ServiceLastUpdate("digest").Digest)
+
+		reg.setDigest("latest", "sha256:bbbb")
+		r, err = oc.Check(ctx)
+		require.NoError(t, err)
+		assert.True(t, r.HasUpdate)
+		assert.Equal(t, "sha256:bbbb", r.Version)
+
+		// The digest advances once the service is updated to it.
+		last := db.ServiceLastUpdate("digest")
+		last.Tag = "sha256:bbbb"
+		require.NoError(t, db.SetServiceLastUpdate("digest", last))
+		r, err = oc.Check(ctx)
+		require.NoError(t, err)
+		assert.False(t, r.HasUpdate)
+		assert.Equal(t, "sha256:bbbb", db.ServiceLastUpdate("digest").Digest)
+
+		// The token is reused.
+		assert.Equal(t, tokens+1, reg.tokens)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		cc := cc
+		cc.Password = "wrong"
+		_, err := NewOCIRegistryChecker(db, "unauthorized", ServiceConfig{Checker: cc}).Check(ctx)
+		assert.Error(t, err)
+	})
+}
+
+func TestParseAuthChallenge(t *testing.T) {
+	scheme, params := parseAuthChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/alpine:pull,push"`)
+	assert.Equal(t, "Bearer", scheme)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/alpine:pull,push",
+	}, params)
+
+	scheme, params = parseAuthChallenge(`Basic realm=registry`)
+	assert.Equal(t, "Basic", scheme)
+	assert.Equal(t, map[string]string{"realm": "registry"}, params)
+}
+
+func TestProcessOCIRegistryCheckerConfig(t *testing.T) {
+	cases := []struct {
+		image    string
+		wantURL  string
+		wantRepo string
+	}{
+		{"alpine", "https://registry-1.docker.io", "library/alpine"},
+		{"skycoin/skywire", "https://registry-1.docker.io", "skycoin/skywire"},
+		{"ghcr.io/skycoin/skywire", "https://ghcr.io", "skycoin/skywire"},
+		{"localhost:5000/skywire", "https://localhost:5000", "skywire"},
+	}
+	for _, tc := range cases {
+		baseURL, repo := parseImage(tc.image)
+		assert.Equal(t, tc.wantURL, baseURL, tc.image)
+		assert.Equal(t, tc.wantRepo, repo, tc.image)
+	}
+
+	cc := CheckerConfig{Type: OCIRegistryCheckerType, Image: "skycoin/skywire"}
+	require.NoError(t, processOCIRegistryCheckerConfig(&cc))
+	assert.Equal(t, DefaultOCITag, cc.Tag)
+
+	assert.Error(t, processOCIRegistryCheckerConfig(&CheckerConfig{Type: OCIRegistryCheckerType}))
+	assert.Error(t, processOCIRegistryCheckerConfig(&CheckerConfig{Type: OCIRegistryCheckerType, Image: "skycoin/skywire:latest"}))
+	assert.Error(t, processOCIRegistryCheckerConfig(&CheckerConfig{Type: OCIRegistryCheckerType, Image: "skycoin/skywire", Tag: "latest", Tags: "v*"}))
+}
@@ -0,0 +1,376 @@
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+)
+
+const (
+	// DefaultOutputRingSize is the number of most recent lines of a run which
+	// are kept in memory for streaming.
+	DefaultOutputRingSize = 1000
+
+	// outputSubBuffer is the number of lines buffered for each subscriber.
+	// Subscribers which fall further behind are dropped.
+	outputSubBuffer = 256
+)
+
+// OutputLine is a line of output of a script.
+type OutputLine struct {
+	Source string    `json:"source"` // "stdout" or "stderr" of scripts, or "updater" for built-in updaters.
+	Text   string    `json:"text"`
+	Time   time.Time `json:"time"`
+}
+
+// Output captures the output lines of the scripts of a run. The most recent
+// lines are kept in a ring buffer, from which subscribers are served. The full
+// transcript is also written to a file, if the run has one.
+type Output struct {
+	id      string
+	srvName string
+	kind    RunKind
+	started time.Time
+	path    string // Transcript file path (if any).
+
+	ring    []OutputLine
+	next    int // Ring index of the next line.
+	count   int // Number of lines captured so far.
+	file    *os.File
+	subs    map[chan OutputLine]struct{}
+	done    chan struct{}
+	endedAt time.Time
+	mu      sync.RWMutex
+}
+
+// NewOutput creates a new Output which only keeps the most recent lines.
+func NewOutput() *Output {
+	return newOutput(newRunID(), "", "", DefaultOutputRingSize)
+}
+
+func newOutput(id, srvName string, kind RunKind, ringSize int) *Output {
+	return &Output{
+		id:      id,
+		srvName: srvName,
+		kind:    kind,
+		started: time.Now(),
+		ring:    make([]OutputLine, ringSize),
+		subs:    make(map[chan OutputLine]struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// newFileOutput creates a new Output which also writes the full transcript
+// to a file in the given directory. The details of the run are kept in a file
+// next to the transcript, so that the run can be loaded by later instances.
+func newFileOutput(dir, id, srvName string, kind RunKind, ringSize int) (*Output, error) {
+	o := newOutput(id, srvName, kind, ringSize)
+	o.path = filepath.Join(dir, id+".jsonl")
+	f, err := os.OpenFile(o.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	o.file = f
+	if err := o.writeInfo(); err != nil {
+		_ = f.Close() //nolint:errcheck
+		o.remove()
+		return nil, err
+	}
+	return o, nil
+}
+
+// loadFileOutput loads an ended run from the transcript file of the given path,
+// which was written by newFileOutput. Runs which were not ended (as the
+// instance which ran them stopped) end with their last line.
+func loadFileOutput(path string, ringSize int) (*Output, error) {
+	raw, err := ioutil.ReadFile(infoPath(path))
+	if err != nil {
+		return nil, err
+	}
+	var info RunInfo
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return nil, fmt.Errorf("invalid run details: %s", err)
+	}
+	o := newOutput(info.ID, info.Service, info.Kind, ringSize)
+	o.started = info.StartedAt
+	o.path = path
+	lines, err := o.Transcript()
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		o.push(line)
+	}
+	switch {
+	case info.EndedAt != nil:
+		o.endedAt = *info.EndedAt
+	case len(lines) > 0:
+		o.endedAt = lines[len(lines)-1].Time
+	default:
+		o.endedAt = o.started
+	}
+	close(o.done)
+	return o, nil
+}
+
+// infoPath returns the path of the file which keeps the details of the run of
+// the given transcript.
+func infoPath(transcript string) string {
+	return strings.TrimSuffix(transcript, ".jsonl") + ".json"
+}
+
+// writeInfo writes the details of the run next to its transcript. The lock
+// should be held (or the run not yet shared).
+func (o *Output) writeInfo() error {
+	raw, err := json.Marshal(o.info())
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(infoPath(o.path), raw, 0600)
+}
+
+// ID returns the ID of the run.
+func (o *Output) ID() string { return o.id }
+
+// Done returns a channel which is closed once the run ends.
+func (o *Output) Done() <-chan struct{} { return o.done }
+
+func (o *Output) ended() bool {
+	select {
+	case <-o.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Info returns a snapshot of the run's details.
+func (o *Output) Info() RunInfo {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.info()
+}
+
+func (o *Output) info() RunInfo {
+	info := RunInfo{
+		ID:        o.id,
+		Service:   o.srvName,
+		Kind:      o.kind,
+		Lines:     o.count,
+		StartedAt: o.started,
+	}
+	if !o.endedAt.IsZero() {
+		ended := o.endedAt
+		info.EndedAt = &ended
+	}
+	return info
+}
+
+// Lines obtains the most recent lines kept in the ring buffer, oldest first.
+func (o *Output) Lines() []OutputLine {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.recent()
+}
+
+func (o *Output) recent() []OutputLine {
+	n := o.count
+	if n > len(o.ring) {
+		n = len(o.ring)
+	}
+	out := make([]OutputLine, 0, n)
+	for i := o.next - n; i < o.next; i++ {
+		out = append(out, o.ring[(i+len(o.ring))%len(o.ring)])
+	}
+	return out
+}
+
+// Transcript obtains all the lines of the run. Without a transcript file, only
+// the most recent lines are available.
+func (o *Output) Transcript() ([]OutputLine, error) {
+	if o.path == "" {
+		return o.Lines(), nil
+	}
+	f, err := os.Open(o.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []OutputLine
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1024*1024)
+	for sc.Scan() {
+		var line OutputLine
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			return out, err
+		}
+		out = append(out, line)
+	}
+	return out, sc.Err()
+}
+
+// Subscribe obtains the lines in the ring buffer, and a channel which receives
+// subsequent lines. The channel is closed once the run ends, or if the
+// subscriber falls too far behind. Unsubscribe should be called once done.
+func (o *Output) Subscribe() ([]OutputLine, <-chan OutputLine) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	ch := make(chan OutputLine, outputSubBuffer)
+	if o.endedAt.IsZero() {
+		o.subs[ch] = struct{}{}
+	} else {
+		close(ch)
+	}
+	return o.recent(), ch
+}
+
+// Unsubscribe stops a channel obtained from Subscribe from receiving lines.
+func (o *Output) Unsubscribe(ch <-chan OutputLine) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for sub := range o.subs {
+		if sub == ch {
+			delete(o.subs, sub)
+			close(sub)
+		}
+	}
+}
+
+func (o *Output) add(source, text string) {
+	line := OutputLine{Source: source, Text: text, Time: time.Now()}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !o.endedAt.IsZero() {
+		return
+	}
+	o.push(line)
+	if o.file != nil {
+		if raw, err := json.Marshal(line); err == nil {
+			if _, err := o.file.Write(append(raw, '\n')); err != nil {
+				log.WithError(err).WithField("run", o.id).Warn("Failed to write transcript.")
+			}
+		}
+	}
+	for sub := range o.subs {
+		select {
+		case sub <- line:
+		default:
+			delete(o.subs, sub)
+			close(sub)
+		}
+	}
+}
+
+// push adds a line to the ring buffer. The lock should be held.
+func (o *Output) push(line OutputLine) {
+	o.ring[o.next] = line
+	o.next = (o.next + 1) % len(o.ring)
+	o.count++
+}
+
+// end marks the run as ended, and closes the transcript and subscribers.
+func (o *Output) end() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !o.endedAt.IsZero() {
+		return
+	}
+	o.endedAt = time.Now()
+	if o.file != nil {
+		if err := o.file.Close(); err != nil {
+			log.WithError(err).WithField("run", o.id).Warn("Failed to close transcript.")
+		}
+		if err := o.writeInfo(); err != nil {
+			log.WithError(err).WithField("run", o.id).Warn("Failed to write run details.")
+		}
+	}
+	for sub := range o.subs {
+		delete(o.subs, sub)
+		close(sub)
+	}
+	close(o.done)
+}
+
+// remove removes the transcript file (if any), along with the run's details.
+func (o *Output) remove() {
+	if o.path == "" {
+		return
+	}
+	for _, name := range []string{o.path, infoPath(o.path)} {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			log.WithError(err).WithField("run", o.id).Warn("Failed to remove transcript.")
+		}
+	}
+}
+
+// Writer returns a writer which captures lines written from the given source.
+// A trailing partial line is captured on Close.
+func (o *Output) Writer(source string) io.WriteCloser {
+	return &lineWriter{o: o, source: source}
+}
+
+type lineWriter struct {
+	o      *Output
+	source string
+	buf    bytes.Buffer
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := w.buf.Next(i + 1)
+		w.o.add(w.source, string(bytes.TrimRight(line, "\r\n")))
+	}
+}
+
+func (w *lineWriter) Close() error {
+	if w.buf.Len() > 0 {
+		w.o.add(w.source, w.buf.String())
+		w.buf.Reset()
+	}
+	return nil
+}
+
+// progress logs a progress message, and captures it into the Output of the
+// context (if any).
+func progress(ctx context.Context, l *logging.Logger, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	l.Info(msg)
+	if o := outputFromContext(ctx); o != nil {
+		o.add("updater", msg)
+	}
+}
+
+type outputKey struct{}
+
+// WithOutput returns a context which makes ExecuteScript capture the script's
+// output into o.
+func WithOutput(ctx context.Context, o *Output) context.Context {
+	return context.WithValue(ctx, outputKey{}, o)
+}
+
+func outputFromContext(ctx context.Context) *Output {
+	o, _ := ctx.Value(outputKey{}).(*Output)
+	return o
+}
@@ -0,0 +1,123 @@
+package update
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutput(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "transcripts")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	o, err := newFileOutput(dir, newRunID(), "srv", CheckRun, 3)
+	require.NoError(t, err)
+
+	w := o.Writer("stdout")
+	for i := 0; i < 4; i++ {
+		_, err := fmt.Fprintf(w, "line%d\n", i)
+		require.NoError(t, err)
+	}
+
+	// Subscribers receive the ring buffer, then subsequent lines.
+	backlog, ch := o.Subscribe()
+	require.Len(t, backlog, 3)
+	assert.Equal(t, "line1", backlog[0].Text)
+	assert.Equal(t, "line3", backlog[2].Text)
+
+	_, err = fmt.Fprint(w, "line4")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	line := <-ch
+	assert.Equal(t, OutputLine{Source: "stdout", Text: "line4", Time: line.Time}, line)
+
+	o.end()
+	_, ok := <-ch
+	assert.False(t, ok)
+	assert.NotNil(t, o.Info().EndedAt)
+	assert.Equal(t, 5, o.Info().Lines)
+
+	// Subscribing to an ended run only obtains the ring buffer.
+	backlog, ch = o.Subscribe()
+	assert.Len(t, backlog, 3)
+	_, ok = <-ch
+	assert.False(t, ok)
+
+	// The transcript contains all lines.
+	lines, err := o.Transcript()
+	require.NoError(t, err)
+	require.Len(t, lines, 5)
+	for i, l := range lines {
+		assert.Equal(t, fmt.Sprintf("line%d", i), l.Text)
+	}
+
+	// The run can be loaded from its transcript by later instances.
+	loaded, err := loadFileOutput(o.path, 3)
+	require.NoError(t, err)
+	assert.Equal(t, o.Info().ID, loaded.Info().ID)
+	assert.Equal(t, "srv", loaded.Info().Service)
+	assert.Equal(t, CheckRun, loaded.Info().Kind)
+	assert.Equal(t, 5, loaded.Info().Lines)
+	assert.True(t, o.Info().EndedAt.Equal(*loaded.Info().EndedAt))
+	assert.True(t, loaded.ended())
+	require.Len(t, loaded.Lines(), 3)
+	assert.Equal(t, "line4", loaded.Lines()[2].Text)
+
+	o.remove()
+	_, err = os.Stat(o.path)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(infoPath(o.path))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRunList(t *testing.T) {
+	l := newRunList(2, 1)
+	run := func(kind RunKind, end bool) *Output {
+		o := newOutput(newRunID(), "srv", kind, 1)
+		if end {
+			o.end()
+		}
+		l.add(o)
+		return o
+	}
+
+	update := run(UpdateRun, true)
+	for i := 0; i < 5; i++ {
+		run(CheckRun, true)
+	}
+
+	// Check runs do not cause update runs to be forgotten.
+	_, err := l.get(update.ID())
+	assert.NoError(t, err)
+	assert.Len(t, l.list("srv"), 3)
+
+	// Runs which did not end are not forgotten.
+	running := run(UpdateRun, false)
+	run(UpdateRun, true)
+	_, err = l.get(update.ID())
+	assert.Equal(t, ErrRunNotFound, err)
+	_, err = l.get(running.ID())
+	assert.NoError(t, err)
+	running.end()
+}
+
+func TestOutput_slowSubscriber(t *testing.T) {
+	o := NewOutput()
+	_, ch := o.Subscribe()
+	for i := 0; i <= outputSubBuffer; i++ {
+		o.add("stdout", "line")
+	}
+	n := 0
+	for range ch {
+		n++
+	}
+	assert.Equal(t, outputSubBuffer, n)
+	o.end()
+}
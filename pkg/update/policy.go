@@ -0,0 +1,132 @@
+package update
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrServicePinned occurs when attempting to update a pinned service to a
+// version other than the one it is pinned to.
+var ErrServicePinned = errors.New("service is pinned to another version")
+
+// PolicyMode determines what the Manager does when a check reports an update.
+type PolicyMode string
+
+const (
+	// NotifyOnlyPolicy only reports available updates (default).
+	NotifyOnlyPolicy = PolicyMode("notify-only")
+
+	// AutoPolicy updates the service as soon as an update is available.
+	AutoPolicy = PolicyMode("auto")
+
+	// PinnedPolicy keeps the service at the pinned version.
+	PinnedPolicy = PolicyMode("pinned")
+)
+
+var policyModes = []PolicyMode{
+	NotifyOnlyPolicy,
+	AutoPolicy,
+	PinnedPolicy,
+}
+
+// PolicyAction is the action decided by a policy.
+type PolicyAction string
+
+const (
+	// NoAction is decided when there is no update available.
+	NoAction = PolicyAction("none")
+
+	// NotifyAction is decided when an update is available, but should not be
+	// applied automatically.
+	NotifyAction = PolicyAction("notify")
+
+	// UpdateAction is decided when an update should be applied automatically.
+	UpdateAction = PolicyAction("update")
+
+	// DeferAction is decided when an update should be applied automatically,
+	// but not before the service's next maintenance window opens.
+	DeferAction = PolicyAction("defer")
+
+	// SkipAction is decided when an update is available, but the service
+	// should not move from its current version.
+	SkipAction = PolicyAction("skip")
+)
+
+// PolicyConfig is the configuration for a service's update policy.
+type PolicyConfig struct {
+	Mode   PolicyMode `yaml:"mode,omitempty"`
+	Pinned string     `yaml:"pinned,omitempty"` // Version the service is pinned to. Implies the "pinned" mode.
+}
+
+// PolicyDecision records what a policy decided after a check, and why.
+type PolicyDecision struct {
+	Mode          PolicyMode   `json:"mode"`
+	Action        PolicyAction `json:"action"`
+	Version       string       `json:"version,omitempty"`
+	Reason        string       `json:"reason"`
+	Error         string       `json:"error,omitempty"`
+	JobID         string       `json:"job_id,omitempty"` // Job of the update applied by the policy.
+	DeferredUntil *time.Time   `json:"deferred_until,omitempty"`
+	Timestamp     time.Time    `json:"timestamp"`
+}
+
+// Decide decides what to do with the given release.
+func (p PolicyConfig) Decide(r *Release) PolicyDecision {
+	d := PolicyDecision{
+		Mode:      p.Mode,
+		Version:   r.Version,
+		Timestamp: time.Now(),
+	}
+	if d.Mode == "" {
+		d.Mode = NotifyOnlyPolicy
+	}
+	if !r.HasUpdate {
+		d.Action = NoAction
+		d.Reason = "no update available"
+		return d
+	}
+	switch d.Mode {
+	case AutoPolicy:
+		d.Action = UpdateAction
+		d.Reason = "update available and policy is auto"
+	case PinnedPolicy:
+		d.Action = SkipAction
+		d.Reason = fmt.Sprintf("service is pinned to version '%s'", p.Pinned)
+	default:
+		d.Action = NotifyAction
+		d.Reason = "update available and policy is notify-only"
+	}
+	return d
+}
+
+// Allows checks whether the policy allows updating to the given version.
+func (p PolicyConfig) Allows(toVersion string) bool {
+	return p.Mode != PinnedPolicy || toVersion == p.Pinned
+}
+
+// Checks for errors and fills unspecified fields with default values.
+// The default policy cannot pin a version, as services are pinned individually.
+func processPolicyConfig(p *PolicyConfig, d *PolicyConfig) error {
+	if d.Mode == PinnedPolicy || d.Pinned != "" {
+		return errors.New("the default policy cannot be 'pinned': define policy.pinned for each pinned service instead")
+	}
+	if p.Mode == "" && p.Pinned != "" {
+		p.Mode = PinnedPolicy
+	}
+	if p.Mode == "" {
+		p.Mode = d.Mode
+	}
+	if p.Mode == "" {
+		p.Mode = NotifyOnlyPolicy
+	}
+	for _, mode := range policyModes {
+		if p.Mode == mode {
+			if mode == PinnedPolicy && p.Pinned == "" {
+				return errors.New("policy.pinned needs to be defined when policy.mode is 'pinned'")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid policy.mode '%s' when expecting: %v", p.Mode, policyModes)
+}
@@ -0,0 +1,123 @@
+package update
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-updater/pkg/store"
+)
+
+func TestPolicyConfig_Decide(t *testing.T) {
+	cases := []struct {
+		Policy    PolicyConfig
+		HasUpdate bool
+		Mode      PolicyMode
+		Action    PolicyAction
+	}{
+		{PolicyConfig{}, true, NotifyOnlyPolicy, NotifyAction},
+		{PolicyConfig{}, false, NotifyOnlyPolicy, NoAction},
+		{PolicyConfig{Mode: NotifyOnlyPolicy}, true, NotifyOnlyPolicy, NotifyAction},
+		{PolicyConfig{Mode: AutoPolicy}, true, AutoPolicy, UpdateAction},
+		{PolicyConfig{Mode: AutoPolicy}, false, AutoPolicy, NoAction},
+		{PolicyConfig{Mode: PinnedPolicy, Pinned: "v1.0"}, true, PinnedPolicy, SkipAction},
+		{PolicyConfig{Mode: PinnedPolicy, Pinned: "v1.0"}, false, PinnedPolicy, NoAction},
+	}
+	for i, c := range cases {
+		d := c.Policy.Decide(&Release{HasUpdate: c.HasUpdate, Version: "v2.0"})
+		assert.Equal(t, c.Mode, d.Mode, i)
+		assert.Equal(t, c.Action, d.Action, i)
+		assert.Equal(t, "v2.0", d.Version, i)
+		assert.NotEmpty(t, d.Reason, i)
+	}
+}
+
+func TestProcessPolicyConfig(t *testing.T) {
+	d := PolicyConfig{Mode: AutoPolicy}
+
+	p := PolicyConfig{}
+	require.NoError(t, processPolicyConfig(&p, &d))
+	assert.Equal(t, AutoPolicy, p.Mode)
+
+	p = PolicyConfig{Pinned: "v1.0"}
+	require.NoError(t, processPolicyConfig(&p, &d))
+	assert.Equal(t, PinnedPolicy, p.Mode)
+
+	p = PolicyConfig{}
+	require.NoError(t, processPolicyConfig(&p, &PolicyConfig{}))
+	assert.Equal(t, NotifyOnlyPolicy, p.Mode)
+
+	p = PolicyConfig{Mode: PinnedPolicy}
+	assert.Error(t, processPolicyConfig(&p, &d))
+
+	p = PolicyConfig{Mode: "unknown"}
+	assert.Error(t, processPolicyConfig(&p, &d))
+
+	// Versions are only pinned by services.
+	p = PolicyConfig{}
+	assert.Error(t, processPolicyConfig(&p, &PolicyConfig{Mode: PinnedPolicy}))
+	assert.Error(t, processPolicyConfig(&p, &PolicyConfig{Pinned: "v1.0"}))
+}
+
+func TestManager_applyPolicy(t *testing.T) {
+	check, rmCheck := prepareScript(t, "exit 0")
+	defer rmCheck()
+	update, rmUpdate := prepareScript(t, "exit 0")
+	defer rmUpdate()
+	db, rmDB := prepareDB(t)
+	defer rmDB()
+
+	srv := func(p PolicyConfig) *ServiceConfig {
+		return &ServiceConfig{
+			CheckInterval: -1,
+			Policy:        p,
+			Checker:       CheckerConfig{Type: ScriptCheckerType, Interpreter: "/bin/bash", Script: check},
+			Updater:       UpdaterConfig{Type: ScriptUpdaterType, Interpreter: "/bin/bash", Script: update},
+		}
+	}
+	conf := NewConfig(os.TempDir(), os.TempDir())
+	conf.Services.Services["auto"] = srv(PolicyConfig{Mode: AutoPolicy})
+	conf.Services.Services["notify"] = srv(PolicyConfig{Mode: NotifyOnlyPolicy})
+	conf.Services.Services["pinned"] = srv(PolicyConfig{Mode: PinnedPolicy, Pinned: "v1.0"})
+	m := NewManager(db, conf)
+	defer func() {
+		require.NoError(t, m.Close())
+	}()
+
+	for name, action := range map[string]PolicyAction{
+		"auto":   UpdateAction,
+		"notify": NotifyAction,
+		"pinned": SkipAction,
+	} {
+		_, err := m.Check(context.TODO(), name)
+		require.NoError(t, err, name)
+		status, err := m.LastCheck(name)
+		require.NoError(t, err, name)
+		require.NotNil(t, status.Decision, name)
+		assert.Equal(t, action, status.Decision.Action, name)
+		assert.Empty(t, status.Decision.Error, name)
+		if action == UpdateAction {
+			// The update runs as a job of its own.
+			job, err := m.jobs.get(status.Decision.JobID)
+			require.NoError(t, err, name)
+			<-job.Done()
+		}
+		assert.Equal(t, action == UpdateAction, !db.ServiceLastUpdate(name).IsEmpty(), name)
+	}
+
+	history, err := m.History("auto")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, TriggerPolicy, history[0].Trigger)
+	assert.Equal(t, store.OutcomeSucceeded, history[0].Outcome)
+
+	_, err = m.Update(context.TODO(), "pinned", "v2.0", UpdateOptions{})
+	assert.Equal(t, ErrServicePinned, err)
+
+	ok, err := m.Update(context.TODO(), "pinned", "v1.0", UpdateOptions{})
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
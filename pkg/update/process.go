@@ -0,0 +1,56 @@
+package update
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// findProcesses finds the IDs of the running processes whose executable is
+// named name. Processes are found from /proc if available, or with pgrep
+// otherwise.
+func findProcesses(name string) ([]int, error) {
+	infos, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return pgrep(name)
+	}
+	var pids []int
+	for _, info := range infos {
+		pid, err := strconv.Atoi(info.Name())
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		cmdline, err := ioutil.ReadFile(filepath.Join("/proc", info.Name(), "cmdline"))
+		if err != nil || len(cmdline) == 0 {
+			continue // The process has exited, or is a kernel thread.
+		}
+		argv0 := cmdline
+		if i := bytes.IndexByte(cmdline, 0); i >= 0 {
+			argv0 = cmdline[:i]
+		}
+		if filepath.Base(string(argv0)) == name {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+func pgrep(name string) ([]int, error) {
+	out, err := exec.Command("pgrep", "-x", name).Output() //nolint:gosec
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitCode(exitErr) == 1 {
+			return nil, nil // No processes matched.
+		}
+		return nil, err
+	}
+	var pids []int
+	for _, field := range strings.Fields(string(out)) {
+		if pid, err := strconv.Atoi(field); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
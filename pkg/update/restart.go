@@ -0,0 +1,292 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+)
+
+// RestartType determines how a service is restarted after an update.
+type RestartType string
+
+const (
+	// SignalRestart signals the processes named 'main-process', which are
+	// expected to be restarted by whatever started them.
+	SignalRestart = RestartType("signal")
+
+	// CommandRestart runs a command which restarts the service.
+	CommandRestart = RestartType("command")
+
+	// SupervisorRestart asks a registered Supervisor to restart the service.
+	SupervisorRestart = RestartType("supervisor")
+)
+
+var restartTypes = []RestartType{
+	SignalRestart,
+	CommandRestart,
+	SupervisorRestart,
+}
+
+// Default restart values.
+const (
+	DefaultRestartSignal  = "SIGTERM"
+	DefaultRestartTimeout = 30 * time.Second
+)
+
+// restartPollInterval is the interval at which processes are listed while
+// waiting for the restarted process.
+var restartPollInterval = 100 * time.Millisecond
+
+var signals = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+	"SIGTERM": syscall.SIGTERM,
+}
+
+// RestartError occurs when a service cannot be restarted after an update.
+type RestartError struct {
+	Err        error
+	RolledBack bool // Whether the previous binaries were restored.
+}
+
+// Error implements error.
+func (e *RestartError) Error() string {
+	msg := fmt.Sprintf("failed to restart service: %s", e.Err)
+	if e.RolledBack {
+		msg += " (rolled back)"
+	}
+	return msg
+}
+
+// RestartConfig configures how a service is restarted once its binaries are
+// swapped. Services are not restarted if no type is specified.
+type RestartConfig struct {
+	Type     RestartType   `yaml:"type,omitempty"`
+	Timeout  time.Duration `yaml:"timeout,omitempty"`  // Time to wait for the new process to appear.
+	Rollback bool          `yaml:"rollback,omitempty"` // Restore the previous binaries if the service cannot be restarted.
+
+	// signal restart fields:
+	Signal string `yaml:"signal,omitempty"`
+
+	// command restart fields:
+	Command []string `yaml:"command,omitempty"`
+
+	// supervisor restart fields:
+	Supervisor string `yaml:"supervisor,omitempty"` // Name of a registered supervisor.
+	Unit       string `yaml:"unit,omitempty"`       // Name of the service known to the supervisor (the service name if unspecified).
+}
+
+// Enabled returns whether the service is restarted.
+func (r RestartConfig) Enabled() bool {
+	return r.Type != ""
+}
+
+// Supervisor restarts the services it supervises.
+type Supervisor interface {
+	Restart(ctx context.Context, unit string) error
+}
+
+var (
+	supervisors = map[string]Supervisor{
+		"systemd": systemd{},
+	}
+	supervisorsMu sync.RWMutex
+)
+
+// RegisterSupervisor makes a supervisor available to 'supervisor' restarts
+// under the given name. It should be called before the config is parsed.
+func RegisterSupervisor(name string, s Supervisor) {
+	supervisorsMu.Lock()
+	supervisors[name] = s
+	supervisorsMu.Unlock()
+}
+
+func supervisor(name string) (Supervisor, bool) {
+	supervisorsMu.RLock()
+	s, ok := supervisors[name]
+	supervisorsMu.RUnlock()
+	return s, ok
+}
+
+// systemd restarts systemd units via systemctl.
+type systemd struct{}
+
+func (systemd) Restart(ctx context.Context, unit string) error {
+	out, err := exec.CommandContext(ctx, "systemctl", "restart", unit).CombinedOutput() //nolint:gosec
+	if err != nil {
+		return fmt.Errorf("systemctl restart %s: %s: %s", unit, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Restarter restarts a service.
+type Restarter struct {
+	srvName string
+	c       ServiceConfig
+	log     *logging.Logger
+}
+
+// NewRestarter creates a new Restarter.
+func NewRestarter(srvName string, c ServiceConfig) *Restarter {
+	return &Restarter{
+		srvName: srvName,
+		c:       c,
+		log:     logging.MustGetLogger("restart." + srvName),
+	}
+}
+
+// Restart restarts the service, and waits for a process named 'main-process'
+// which was not running before to appear.
+func (r *Restarter) Restart(ctx context.Context) error {
+	rc := r.c.Restart
+	old, err := r.processes()
+	if err != nil {
+		return err
+	}
+
+	switch rc.Type {
+	case SignalRestart:
+		sig, ok := signals[rc.Signal]
+		if !ok {
+			return fmt.Errorf("signal '%s' is not supported", rc.Signal)
+		}
+		for pid := range old {
+			progress(ctx, r.log, "Sending %s to '%s' (pid %d).", rc.Signal, r.c.MainProcess, pid)
+			if err := syscall.Kill(pid, sig); err != nil && err != syscall.ESRCH {
+				return fmt.Errorf("failed to signal pid %d: %s", pid, err)
+			}
+		}
+	case CommandRestart:
+		progress(ctx, r.log, "Running restart command %v.", rc.Command)
+		if err := r.runCommand(ctx); err != nil {
+			return fmt.Errorf("restart command failed: %s", err)
+		}
+	case SupervisorRestart:
+		s, ok := supervisor(rc.Supervisor)
+		if !ok {
+			return fmt.Errorf("supervisor '%s' is not registered", rc.Supervisor)
+		}
+		unit := rc.Unit
+		if unit == "" {
+			unit = r.srvName
+		}
+		progress(ctx, r.log, "Asking supervisor '%s' to restart '%s'.", rc.Supervisor, unit)
+		if err := s.Restart(ctx, unit); err != nil {
+			return fmt.Errorf("supervisor '%s' failed to restart '%s': %s", rc.Supervisor, unit, err)
+		}
+	default:
+		return fmt.Errorf("invalid restart type '%s'", rc.Type)
+	}
+
+	return r.waitNew(ctx, old)
+}
+
+func (r *Restarter) runCommand(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, r.c.Restart.Command[0], r.c.Restart.Command[1:]...) //nolint:gosec
+	l := r.log.WithField("command", r.c.Restart.Command[0])
+	cmd.Stdout = l.WithField("source", "stdout").Writer()
+	cmd.Stderr = l.WithField("source", "stderr").Writer()
+	if o := outputFromContext(ctx); o != nil {
+		stdout, stderr := o.Writer("stdout"), o.Writer("stderr")
+		defer func() {
+			stdout.Close()
+			stderr.Close()
+		}()
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, stdout)
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, stderr)
+	}
+	return cmd.Run()
+}
+
+// waitNew waits for a process named 'main-process' which is not in old.
+func (r *Restarter) waitNew(ctx context.Context, old map[int]bool) error {
+	ctx, cancel := context.WithTimeout(ctx, r.c.Restart.Timeout)
+	defer cancel()
+	ticker := time.NewTicker(restartPollInterval)
+	defer ticker.Stop()
+	for {
+		pids, err := r.processes()
+		if err != nil {
+			return err
+		}
+		for pid := range pids {
+			if !old[pid] {
+				progress(ctx, r.log, "Restarted '%s' (pid %d).", r.c.MainProcess, pid)
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("new '%s' process did not appear within %s", r.c.MainProcess, r.c.Restart.Timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// processes lists the processes named 'main-process' (other than the updater).
+func (r *Restarter) processes() (map[int]bool, error) {
+	pids, err := findProcesses(r.c.MainProcess)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %s", err)
+	}
+	out := make(map[int]bool, len(pids))
+	for _, pid := range pids {
+		if pid != os.Getpid() {
+			out[pid] = true
+		}
+	}
+	return out, nil
+}
+
+// Checks for errors and fills unspecified fields with default values.
+func processRestartConfig(r *RestartConfig, sc *ServiceConfig) error {
+	if !r.Enabled() {
+		return nil
+	}
+	if sc.MainProcess == "" {
+		return errors.New("main-process needs to be defined to restart the service")
+	}
+	if r.Timeout == 0 {
+		r.Timeout = DefaultRestartTimeout
+	}
+	if r.Timeout < 0 {
+		return errors.New("restart.timeout cannot be negative")
+	}
+	switch r.Type {
+	case SignalRestart:
+		if r.Signal == "" {
+			r.Signal = DefaultRestartSignal
+		}
+		r.Signal = strings.ToUpper(r.Signal)
+		if !strings.HasPrefix(r.Signal, "SIG") {
+			r.Signal = "SIG" + r.Signal
+		}
+		if _, ok := signals[r.Signal]; !ok {
+			return fmt.Errorf("restart.signal '%s' is not supported", r.Signal)
+		}
+	case CommandRestart:
+		if len(r.Command) == 0 {
+			return errors.New("restart.command needs to be defined")
+		}
+	case SupervisorRestart:
+		if _, ok := supervisor(r.Supervisor); !ok {
+			return fmt.Errorf("restart.supervisor '%s' is not registered", r.Supervisor)
+		}
+	default:
+		return fmt.Errorf("invalid restart.type '%s' when expecting: %v", r.Type, restartTypes)
+	}
+	return nil
+}
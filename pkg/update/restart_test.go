@@ -0,0 +1,176 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// prepareProcess copies the sleep binary under a unique name, so that it can
+// be used as the main process of a service.
+func prepareProcess(t *testing.T) (string, string, func()) {
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	require.NoError(t, err)
+	name := fmt.Sprintf("swu-test-%s", newRunID())
+	bin := filepath.Join(dir, name)
+	require.NoError(t, linkOrCopy("/bin/sleep", bin))
+	rm := func() {
+		pids, err := findProcesses(name)
+		require.NoError(t, err)
+		for _, pid := range pids {
+			_ = syscall.Kill(pid, syscall.SIGKILL) //nolint:errcheck
+		}
+		require.NoError(t, os.RemoveAll(dir))
+	}
+	return name, bin, rm
+}
+
+// startProcess starts the given binary, and returns a channel which is closed
+// once it exits.
+func startProcess(t *testing.T, bin string) (*exec.Cmd, <-chan struct{}) {
+	cmd := exec.Command(bin, "30") //nolint:gosec
+	require.NoError(t, cmd.Start())
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait() //nolint:errcheck
+		close(exited)
+	}()
+	return cmd, exited
+}
+
+type testSupervisor struct {
+	restart func() error
+}
+
+func (s testSupervisor) Restart(context.Context, string) error {
+	return s.restart()
+}
+
+func TestRestarter_Restart(t *testing.T) {
+	t.Run("signal", func(t *testing.T) {
+		name, bin, rm := prepareProcess(t)
+		defer rm()
+		old, exited := startProcess(t, bin)
+
+		// Restart the process once it exits, as a supervisor would.
+		restarted := make(chan *exec.Cmd, 1)
+		go func() {
+			<-exited
+			cmd, _ := startProcess(t, bin)
+			restarted <- cmd
+		}()
+
+		c := ServiceConfig{
+			MainProcess: name,
+			Restart:     RestartConfig{Type: SignalRestart, Signal: "SIGTERM", Timeout: 5 * time.Second},
+		}
+		require.NoError(t, NewRestarter("srv", c).Restart(context.Background()))
+		assert.NotEqual(t, old.Process.Pid, (<-restarted).Process.Pid)
+	})
+
+	t.Run("command", func(t *testing.T) {
+		name, bin, rm := prepareProcess(t)
+		defer rm()
+
+		c := ServiceConfig{
+			MainProcess: name,
+			Restart: RestartConfig{
+				Type:    CommandRestart,
+				Command: []string{"/bin/sh", "-c", fmt.Sprintf("%s 30 >/dev/null 2>&1 &", bin)},
+				Timeout: 5 * time.Second,
+			},
+		}
+		require.NoError(t, NewRestarter("srv", c).Restart(context.Background()))
+		pids, err := findProcesses(name)
+		require.NoError(t, err)
+		assert.Len(t, pids, 1)
+	})
+
+	t.Run("supervisor", func(t *testing.T) {
+		name, bin, rm := prepareProcess(t)
+		defer rm()
+		old, exited := startProcess(t, bin)
+
+		RegisterSupervisor("test", testSupervisor{restart: func() error {
+			if err := old.Process.Kill(); err != nil {
+				return err
+			}
+			<-exited
+			startProcess(t, bin)
+			return nil
+		}})
+		c := ServiceConfig{
+			MainProcess: name,
+			Restart:     RestartConfig{Type: SupervisorRestart, Supervisor: "test", Timeout: 5 * time.Second},
+		}
+		require.NoError(t, NewRestarter("srv", c).Restart(context.Background()))
+	})
+
+	t.Run("not restarted", func(t *testing.T) {
+		name, bin, rm := prepareProcess(t)
+		defer rm()
+		startProcess(t, bin)
+
+		c := ServiceConfig{
+			MainProcess: name,
+			Restart:     RestartConfig{Type: SignalRestart, Signal: "SIGTERM", Timeout: 200 * time.Millisecond},
+		}
+		assert.Error(t, NewRestarter("srv", c).Restart(context.Background()))
+	})
+}
+
+func TestProcessRestartConfig(t *testing.T) {
+	r := RestartConfig{Type: SignalRestart, Signal: "hup"}
+	require.NoError(t, processRestartConfig(&r, &ServiceConfig{MainProcess: "skywire-node"}))
+	assert.Equal(t, "SIGHUP", r.Signal)
+	assert.Equal(t, DefaultRestartTimeout, r.Timeout)
+
+	assert.Error(t, processRestartConfig(&RestartConfig{Type: SignalRestart}, &ServiceConfig{}))
+	assert.Error(t, processRestartConfig(&RestartConfig{Type: SignalRestart, Signal: "SIGWHAT"}, &ServiceConfig{MainProcess: "skywire-node"}))
+	assert.Error(t, processRestartConfig(&RestartConfig{Type: CommandRestart}, &ServiceConfig{MainProcess: "skywire-node"}))
+	assert.Error(t, processRestartConfig(&RestartConfig{Type: SupervisorRestart, Supervisor: "unknown"}, &ServiceConfig{MainProcess: "skywire-node"}))
+}
+
+func TestManager_install_restartFailed(t *testing.T) {
+	binDir, rmBin := prepareBinDir(t, map[string]string{"run": "v1"})
+	defer rmBin()
+	update, rmUpdate := prepareScript(t, `echo -n "${SWU_TO_VERSION}" > "${SWU_BIN_DIR}/run"`)
+	defer rmUpdate()
+	db, rmDB := prepareDB(t)
+	defer rmDB()
+
+	RegisterSupervisor("failing", testSupervisor{restart: func() error {
+		return errors.New("unit not found")
+	}})
+	conf := NewConfig(os.TempDir(), os.TempDir())
+	conf.Services.Services["srv"] = &ServiceConfig{
+		BinDir:        binDir,
+		MainProcess:   "swu-test-nonexistent",
+		CheckInterval: -1,
+		Restart:       RestartConfig{Type: SupervisorRestart, Supervisor: "failing", Timeout: time.Second, Rollback: true},
+		Checker:       CheckerConfig{Type: ScriptCheckerType},
+		Updater:       UpdaterConfig{Type: ScriptUpdaterType, Interpreter: "/bin/bash", Script: update},
+	}
+	m := NewManager(db, conf)
+	defer func() {
+		require.NoError(t, m.Close())
+	}()
+
+	updated, err := m.Update(context.Background(), "srv", "v2", UpdateOptions{})
+	assert.False(t, updated)
+	rErr, ok := err.(*RestartError)
+	require.True(t, ok, err)
+	assert.True(t, rErr.RolledBack)
+	assert.Equal(t, map[string]string{"run": "v1"}, readBins(t, binDir))
+	assert.True(t, db.ServiceLastUpdate("srv").IsRolledBack("v2"))
+}
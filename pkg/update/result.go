@@ -0,0 +1,164 @@
+package update
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// scriptResultPollInterval is the interval at which the result file of a
+// running script is read for new progress events.
+const scriptResultPollInterval = 250 * time.Millisecond
+
+// ScriptResult is the structured result of a checker or updater script.
+//
+// Scripts may write JSON documents to the file named by the SWU_RESULT_FILE
+// environment variable. The file may contain a single document, or a sequence
+// of documents (such as one per line) which are appended while the script
+// runs. Fields of later documents override those of earlier ones, and the
+// progress events of all documents are captured into the script's output (and
+// the progress of its job) as they are written.
+type ScriptResult struct {
+	Version   string     `json:"version,omitempty"`   // Version of the release found by a checker.
+	Timestamp time.Time  `json:"timestamp,omitempty"` // Time of the release.
+	Notes     string     `json:"notes,omitempty"`     // Release notes.
+	Artifacts []Artifact `json:"artifacts,omitempty"` // Downloadable files of the release.
+	Progress  []Progress `json:"progress,omitempty"`  // Progress events, in order.
+}
+
+// Progress is a progress event of a script.
+type Progress struct {
+	Message string   `json:"message,omitempty"`
+	Percent *float64 `json:"percent,omitempty"` // Completion percentage (if known).
+}
+
+// String returns the message of the event, along with its percentage.
+func (p Progress) String() string {
+	if p.Percent == nil {
+		return p.Message
+	}
+	if p.Message == "" {
+		return fmt.Sprintf("%g%%", *p.Percent)
+	}
+	return fmt.Sprintf("%s (%g%%)", p.Message, *p.Percent)
+}
+
+// ScriptResultError occurs when the result file of a script is invalid.
+type ScriptResultError struct {
+	Err error
+}
+
+// Error implements error.
+func (e *ScriptResultError) Error() string {
+	return fmt.Sprintf("invalid script result: %s", e.Err)
+}
+
+// scriptResultDoc is a document of a result file.
+type scriptResultDoc struct {
+	Version   string          `json:"version"`
+	Timestamp interface{}     `json:"timestamp"` // RFC 3339 time or unix timestamp.
+	Notes     string          `json:"notes"`
+	Artifacts []Artifact      `json:"artifacts"`
+	Progress  json.RawMessage `json:"progress"` // An event, or a list of them.
+}
+
+// parseScriptResult parses the documents of a result file. It returns nil if
+// the file is empty, and the result of the documents preceding the first
+// invalid (or incomplete) document along with an error.
+func parseScriptResult(raw []byte) (*ScriptResult, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, nil
+	}
+	var result ScriptResult
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	for {
+		var doc scriptResultDoc
+		if err := dec.Decode(&doc); err == io.EOF {
+			return &result, nil
+		} else if err != nil {
+			return &result, err
+		}
+		if doc.Version != "" {
+			result.Version = doc.Version
+		}
+		switch ts := doc.Timestamp.(type) {
+		case nil:
+		case string:
+			t, err := time.Parse(time.RFC3339, ts)
+			if err != nil {
+				return &result, fmt.Errorf("timestamp is not a valid time: %s", err)
+			}
+			result.Timestamp = t
+		case float64:
+			result.Timestamp = time.Unix(int64(ts), 0)
+		default:
+			return &result, fmt.Errorf("timestamp is not a time")
+		}
+		if doc.Notes != "" {
+			result.Notes = doc.Notes
+		}
+		if doc.Artifacts != nil {
+			result.Artifacts = doc.Artifacts
+		}
+		if len(doc.Progress) > 0 {
+			events, err := parseProgress(doc.Progress)
+			if err != nil {
+				return &result, err
+			}
+			result.Progress = append(result.Progress, events...)
+		}
+	}
+}
+
+// parseProgress parses progress events: a message, an object with a message
+// and/or a percentage, or a list of them.
+func parseProgress(raw json.RawMessage) ([]Progress, error) {
+	var list []json.RawMessage
+	if err := json.Unmarshal(raw, &list); err != nil {
+		list = []json.RawMessage{raw}
+	}
+	events := make([]Progress, len(list))
+	for i, raw := range list {
+		if err := json.Unmarshal(raw, &events[i].Message); err == nil {
+			continue
+		}
+		if err := json.Unmarshal(raw, &events[i]); err != nil || (events[i].Message == "" && events[i].Percent == nil) {
+			return nil, fmt.Errorf("progress is not an event or a list of events")
+		}
+		if p := events[i].Percent; p != nil && (*p < 0 || *p > 100) {
+			return nil, fmt.Errorf("progress percent %g is not between 0 and 100", *p)
+		}
+	}
+	return events, nil
+}
+
+// resultFile is the result file of a running script.
+type resultFile struct {
+	path     string
+	emit     func(p Progress) // Captures a progress event.
+	progress int              // Number of progress events captured so far.
+}
+
+// read parses the result file, and captures the progress events which were
+// not captured yet. If the file was rewritten with fewer progress events than
+// were captured, the events it now contains are taken to be captured.
+func (rf *resultFile) read() (*ScriptResult, error) {
+	raw, err := ioutil.ReadFile(rf.path)
+	if err != nil {
+		return nil, err
+	}
+	result, err := parseScriptResult(raw)
+	if result != nil {
+		if len(result.Progress) < rf.progress {
+			rf.progress = len(result.Progress)
+		}
+		for _, p := range result.Progress[rf.progress:] {
+			rf.emit(p)
+		}
+		rf.progress = len(result.Progress)
+	}
+	return result, err
+}
@@ -0,0 +1,42 @@
+package update
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScriptResult(t *testing.T) {
+	percent := 90.0
+	result, err := parseScriptResult([]byte(" \n"))
+	require.NoError(t, err)
+	assert.Nil(t, result)
+
+	// Later documents override earlier ones, and progress events accumulate.
+	result, err = parseScriptResult([]byte(`{"progress": "building", "version": "v0.1.0"}
+{"progress": ["testing", {"message": "packaging", "percent": 90}], "timestamp": 1554112800}
+{"version": "v0.2.0", "notes": "Fixes."}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, &ScriptResult{
+		Version:   "v0.2.0",
+		Timestamp: time.Unix(1554112800, 0),
+		Notes:     "Fixes.",
+		Progress:  []Progress{{Message: "building"}, {Message: "testing"}, {Message: "packaging", Percent: &percent}},
+	}, result)
+	assert.Equal(t, "packaging (90%)", result.Progress[2].String())
+
+	// Documents preceding an incomplete document are parsed.
+	result, err = parseScriptResult([]byte(`{"progress": "building"}
+{"progress": "test`))
+	assert.Error(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, []Progress{{Message: "building"}}, result.Progress)
+
+	for _, raw := range []string{`{"timestamp": "yesterday"}`, `{"timestamp": true}`, `{"progress": 1}`, `{"progress": {}}`, `{"progress": {"percent": 150}}`, `[]`} {
+		_, err := parseScriptResult([]byte(raw))
+		assert.Error(t, err, raw)
+	}
+}
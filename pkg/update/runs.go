@@ -0,0 +1,127 @@
+package update
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRunNotFound occurs when a run is not found.
+var ErrRunNotFound = errors.New("run of given id is not found")
+
+const (
+	// DefaultMaxCheckRuns is the number of check runs whose output is kept.
+	// The oldest finished check runs (and their transcripts) are forgotten
+	// first.
+	DefaultMaxCheckRuns = 100
+
+	// DefaultMaxUpdateRuns is the number of update runs whose output is kept.
+	// Update runs are counted separately from check runs, so that frequent
+	// checks do not cause the output of updates to be forgotten.
+	DefaultMaxUpdateRuns = 100
+)
+
+// RunKind is the kind of a run of a service's scripts.
+type RunKind string
+
+const (
+	// CheckRun is a run of a service's checker.
+	CheckRun = RunKind("check")
+
+	// UpdateRun is a run of a service's updater. Its ID is the ID of the job.
+	UpdateRun = RunKind("update")
+)
+
+// RunInfo is a snapshot of a run.
+type RunInfo struct {
+	ID        string     `json:"id"`
+	Service   string     `json:"service"`
+	Kind      RunKind    `json:"kind"`
+	Lines     int        `json:"lines"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+}
+
+// RunTranscript is a run along with its full output.
+type RunTranscript struct {
+	RunInfo
+	Output []OutputLine `json:"output"`
+}
+
+func newRunID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
+
+// runList keeps track of the output of the most recent runs of each kind.
+type runList struct {
+	max   map[RunKind]int
+	byID  map[string]*Output
+	order []*Output // Oldest first.
+	mu    sync.RWMutex
+}
+
+func newRunList(maxChecks, maxUpdates int) *runList {
+	return &runList{
+		max:  map[RunKind]int{CheckRun: maxChecks, UpdateRun: maxUpdates},
+		byID: make(map[string]*Output),
+	}
+}
+
+func (l *runList) add(o *Output) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.byID[o.id] = o
+	l.order = append(l.order, o)
+
+	// Forget the oldest finished runs of the same kind.
+	n := 0
+	for _, run := range l.order {
+		if run.kind == o.kind {
+			n++
+		}
+	}
+	limit, ok := l.max[o.kind]
+	for i := 0; ok && n > limit && i < len(l.order); {
+		if old := l.order[i]; old.kind == o.kind && old.ended() {
+			old.remove()
+			delete(l.byID, old.id)
+			l.order = append(l.order[:i], l.order[i+1:]...)
+			n--
+			continue
+		}
+		i++
+	}
+}
+
+func (l *runList) get(id string) (*Output, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	o, ok := l.byID[id]
+	if !ok {
+		return nil, ErrRunNotFound
+	}
+	return o, nil
+}
+
+// list lists the runs of the given service (or all runs if srvName is empty),
+// newest first.
+func (l *runList) list(srvName string) []*Output {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	out := make([]*Output, 0, len(l.order))
+	for i := len(l.order) - 1; i >= 0; i-- {
+		if o := l.order[i]; srvName == "" || o.srvName == srvName {
+			out = append(out, o)
+		}
+	}
+	return out
+}
@@ -0,0 +1,191 @@
+package update
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCheckJitter is the fraction of a check interval that scheduled
+	// checks are randomly shifted by, so that services do not all check at once.
+	DefaultCheckJitter = 0.1
+
+	// DefaultCheckMinBackoff is the delay before retrying a scheduled check
+	// after its first failure. It doubles on each consecutive failure, and is
+	// capped by the service's check interval.
+	DefaultCheckMinBackoff = 30 * time.Second
+)
+
+// CheckStatus is the cached result of the most recent check of a service.
+type CheckStatus struct {
+	RunID     string          `json:"run_id,omitempty"` // ID of the run with the checker's output.
+	Release   *Release        `json:"release,omitempty"`
+	Decision  *PolicyDecision `json:"policy_decision,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Failures  int             `json:"consecutive_failures"`
+	CheckedAt time.Time       `json:"checked_at"`
+	NextCheck time.Time       `json:"next_check"`
+}
+
+// scheduler periodically runs the checkers of services and caches the results.
+type scheduler struct {
+	check      func(ctx context.Context, srvName string) (*Release, error)
+	jitter     float64
+	minBackoff time.Duration
+
+	statuses map[string]CheckStatus
+	mu       sync.RWMutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newScheduler(check func(ctx context.Context, srvName string) (*Release, error)) *scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &scheduler{
+		check:      check,
+		jitter:     DefaultCheckJitter,
+		minBackoff: DefaultCheckMinBackoff,
+		statuses:   make(map[string]CheckStatus),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// start schedules periodic checks for the given service.
+// Nothing is scheduled if the interval is not positive.
+func (s *scheduler) start(srvName string, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(srvName, interval)
+	}()
+}
+
+func (s *scheduler) run(srvName string, interval time.Duration) {
+	l := log.WithField("service", srvName)
+	l.Infof("Scheduling checks every %s.", interval)
+
+	// The first check is spread across the jitter window.
+	delay := time.Duration(rand.Int63n(int64(float64(interval)*s.jitter) + 1)) //nolint:gosec
+	failures := 0
+	for {
+		s.setNextCheck(srvName, time.Now().Add(delay))
+		timer := time.NewTimer(delay)
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if _, err := s.check(s.ctx, srvName); err != nil {
+			if s.ctx.Err() != nil {
+				return
+			}
+			failures++
+			delay = s.backoff(failures, interval)
+			l.WithError(err).Warnf("Scheduled check failed %d time(s) in a row, retrying in %s.", failures, delay)
+			continue
+		}
+		failures = 0
+		delay = s.jittered(interval)
+
+		// Check again as soon as the maintenance window opens, if an automatic
+		// update was deferred until then.
+		if dec := s.status(srvName).Decision; dec != nil && dec.Action == DeferAction && dec.DeferredUntil != nil {
+			if until := time.Until(*dec.DeferredUntil); until < delay {
+				delay = until
+			}
+		}
+	}
+}
+
+// backoff returns the delay before the next check after n consecutive failures.
+func (s *scheduler) backoff(n int, interval time.Duration) time.Duration {
+	d := s.minBackoff
+	for i := 1; i < n && d < interval; i++ {
+		d *= 2
+	}
+	if d <= 0 || d > interval {
+		d = interval
+	}
+	return s.jittered(d)
+}
+
+// jittered randomly shifts d by up to the jitter fraction in either direction.
+func (s *scheduler) jittered(d time.Duration) time.Duration {
+	j := int64(float64(d) * s.jitter)
+	if j <= 0 {
+		return d
+	}
+	return d + time.Duration(rand.Int63n(2*j+1)-j) //nolint:gosec
+}
+
+// record caches the result of a check of the given service.
+func (s *scheduler) record(srvName, runID string, release *Release, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.statuses[srvName]
+	status.RunID = runID
+	status.CheckedAt = time.Now()
+	if err != nil {
+		status.Error = err.Error()
+		status.Failures++
+	} else {
+		status.Release = release
+		status.Error = ""
+		status.Failures = 0
+	}
+	s.statuses[srvName] = status
+}
+
+// recordDecision caches the policy decision made after a check of the given service.
+func (s *scheduler) recordDecision(srvName string, decision PolicyDecision) {
+	s.mu.Lock()
+	status := s.statuses[srvName]
+	status.Decision = &decision
+	s.statuses[srvName] = status
+	s.mu.Unlock()
+}
+
+// recordJobError records the failure of the job of an update applied by a
+// policy, unless a later decision was made since.
+func (s *scheduler) recordJobError(srvName, jobID string, err error) {
+	s.mu.Lock()
+	status := s.statuses[srvName]
+	if dec := status.Decision; dec != nil && dec.JobID == jobID {
+		decision := *dec
+		decision.Error = err.Error()
+		status.Decision = &decision
+		s.statuses[srvName] = status
+	}
+	s.mu.Unlock()
+}
+
+func (s *scheduler) setNextCheck(srvName string, t time.Time) {
+	s.mu.Lock()
+	status := s.statuses[srvName]
+	status.NextCheck = t
+	s.statuses[srvName] = status
+	s.mu.Unlock()
+}
+
+// status obtains the cached check status of the given service.
+func (s *scheduler) status(srvName string) CheckStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.statuses[srvName]
+}
+
+// stop stops all scheduled checks and waits for running ones to return.
+func (s *scheduler) stop() {
+	s.cancel()
+	s.wg.Wait()
+}
@@ -0,0 +1,116 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-updater/pkg/store"
+)
+
+func prepareDB(t *testing.T) (*store.JSON, func()) {
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	require.NoError(t, err)
+	j, err := store.NewJSON(filepath.Join(dir, "db.json"))
+	require.NoError(t, err)
+	rm := func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}
+	return j, rm
+}
+
+func TestScheduler_backoff(t *testing.T) {
+	s := newScheduler(nil)
+	s.jitter = 0
+	s.minBackoff = time.Second
+
+	assert.Equal(t, time.Second, s.backoff(1, time.Minute))
+	assert.Equal(t, 2*time.Second, s.backoff(2, time.Minute))
+	assert.Equal(t, 8*time.Second, s.backoff(4, time.Minute))
+	assert.Equal(t, time.Minute, s.backoff(10, time.Minute))
+	assert.Equal(t, time.Minute, s.backoff(1000, time.Minute))
+}
+
+func TestScheduler_jittered(t *testing.T) {
+	s := newScheduler(nil)
+	s.jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := s.jittered(time.Second)
+		assert.True(t, d >= 500*time.Millisecond && d <= 1500*time.Millisecond, d)
+	}
+}
+
+func TestScheduler_record(t *testing.T) {
+	s := newScheduler(nil)
+	r := &Release{HasUpdate: true, Version: "v1.0"}
+
+	s.record("srv", "", nil, errors.New("failed"))
+	s.record("srv", "", nil, errors.New("failed"))
+	status := s.status("srv")
+	assert.Equal(t, 2, status.Failures)
+	assert.Equal(t, "failed", status.Error)
+	assert.Nil(t, status.Release)
+
+	s.record("srv", "", r, nil)
+	status = s.status("srv")
+	assert.Equal(t, 0, status.Failures)
+	assert.Empty(t, status.Error)
+	assert.Equal(t, r, status.Release)
+}
+
+func TestManager_scheduledChecks(t *testing.T) {
+	fName, rm := prepareScript(t, "exit 0")
+	defer rm()
+	db, rmDB := prepareDB(t)
+	defer rmDB()
+
+	conf := NewConfig(os.TempDir(), os.TempDir())
+	conf.Services.Defaults.CheckInterval = 20 * time.Millisecond
+	conf.Services.Services["my-service"] = &ServiceConfig{
+		Checker: CheckerConfig{
+			Type:        ScriptCheckerType,
+			Interpreter: "/bin/bash",
+			Script:      fName,
+		},
+		Updater: UpdaterConfig{Type: ScriptUpdaterType},
+	}
+	conf.Services.Services["unscheduled"] = &ServiceConfig{
+		CheckInterval: -1,
+		Checker:       CheckerConfig{Type: ScriptCheckerType},
+		Updater:       UpdaterConfig{Type: ScriptUpdaterType},
+	}
+	m := NewManager(db, conf)
+
+	var status *CheckStatus
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		var err error
+		status, err = m.LastCheck("my-service")
+		require.NoError(t, err)
+		if status.Release != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.NotNil(t, status.Release)
+	assert.True(t, status.Release.HasUpdate)
+	assert.False(t, status.NextCheck.IsZero())
+
+	status, err := m.LastCheck("unscheduled")
+	require.NoError(t, err)
+	assert.True(t, status.CheckedAt.IsZero())
+
+	_, err = m.LastCheck("unknown")
+	assert.Equal(t, ErrServiceNotFound, err)
+
+	_, err = m.Check(context.TODO(), "my-service")
+	require.NoError(t, err)
+
+	require.NoError(t, m.Close())
+}
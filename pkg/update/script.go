@@ -2,23 +2,89 @@ package update
 
 import (
 	"context"
+	"io"
+	"io/ioutil"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/skycoin/skycoin/src/util/logging"
 )
 
 // ExecuteScript executes the provided script and logs stdout.
+// The output is also captured if the context is prepared with WithOutput.
+// An invalid result written by the script is ignored (with a warning), as only
+// its progress events are of use.
 func ExecuteScript(ctx context.Context, log *logging.Logger, cmd *exec.Cmd) (bool, error) {
+	ok, _, err := ExecuteScriptResult(ctx, log, cmd)
+	if _, invalid := err.(*ScriptResultError); invalid {
+		log.WithError(err).Warn("Ignoring invalid script result.")
+		return ok, nil
+	}
+	return ok, err
+}
+
+// ExecuteScriptResult executes the provided script as ExecuteScript does, and
+// also returns the structured result written by the script (nil if it did not
+// write one). The path of the result file is given to the script by the
+// SWU_RESULT_FILE environment variable, and progress events are logged and
+// captured (with source "progress", and as the progress of the job if the
+// context is prepared with withJobProgress) while the script runs. A
+// *ScriptResultError is returned (along with whether the script succeeded) if
+// the result is invalid.
+func ExecuteScriptResult(ctx context.Context, log *logging.Logger, cmd *exec.Cmd) (bool, *ScriptResult, error) {
 	l := log.WithField("script", filepath.Base(cmd.Args[1]))
 
 	l.Infof("START %v", cmd.Args)
 	defer l.Infof("END %v", cmd.Args)
 
+	// Prepare result file.
+	f, err := ioutil.TempFile("", "swu-result-")
+	if err != nil {
+		return false, nil, err
+	}
+	if err := f.Close(); err != nil {
+		return false, nil, err
+	}
+	defer func() {
+		if err := os.Remove(f.Name()); err != nil {
+			l.WithError(err).Warn("Failed to remove result file.")
+		}
+	}()
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, MakeEnv(EnvResultFile, f.Name()))
+	rf := &resultFile{path: f.Name(), emit: func(p Progress) {
+		l.WithField("source", "progress").Info(p.String())
+	}}
+	if job := jobFromContext(ctx); job != nil {
+		emit := rf.emit
+		rf.emit = func(p Progress) {
+			emit(p)
+			job.setProgress(p)
+		}
+	}
+
 	// Prepare logging.
 	cmd.Stdout = l.WithField("source", "stdout").Writer()
 	cmd.Stderr = l.WithField("source", "stderr").Writer()
+	if o := outputFromContext(ctx); o != nil {
+		stdout, stderr := o.Writer("stdout"), o.Writer("stderr")
+		defer func() {
+			stdout.Close()
+			stderr.Close()
+		}()
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, stdout)
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, stderr)
+		emit := rf.emit
+		rf.emit = func(p Progress) {
+			emit(p)
+			o.add("progress", p.String())
+		}
+	}
 
 	// Set process group ID so the cmd and all its children become a new process
 	// group. This allows Stop to SIGTERM the command's process group without
@@ -27,35 +93,50 @@ func ExecuteScript(ctx context.Context, log *logging.Logger, cmd *exec.Cmd) (boo
 
 	// Start command.
 	if err := cmd.Start(); err != nil {
-		return false, err
+		return false, nil, err
 	}
 
-	// Check ctx.
+	// Check ctx, and read progress events while the command runs.
 	done := make(chan struct{})
-	defer close(done)
+	polled := make(chan struct{})
 	go func() {
-		select {
-		case <-done:
-		case <-ctx.Done():
-			l.Info("Context closed")
-			// Signal the process group (-pid), not just the process, so that
-			// the process and all its children are signaled. Else, child procs
-			// can keep running and keep the stdout/stderr fd open and cause
-			// cmd.Wait to hang.
-			if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM); err != nil {
-				l.WithError(err).Error("syscall.Kill returned error")
+		defer close(polled)
+		ticker := time.NewTicker(scriptResultPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_, _ = rf.read() //nolint:errcheck
+			case <-ctx.Done():
+				l.Info("Context closed")
+				// Signal the process group (-pid), not just the process, so that
+				// the process and all its children are signaled. Else, child procs
+				// can keep running and keep the stdout/stderr fd open and cause
+				// cmd.Wait to hang.
+				if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM); err != nil {
+					l.WithError(err).Error("syscall.Kill returned error")
+				}
+				<-done
+				return
 			}
 		}
 	}()
 
-	if err := cmd.Wait(); err != nil {
+	err = cmd.Wait()
+	close(done)
+	<-polled
+	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); !ok || exitCode(exitErr) != 1 {
-			return false, err
+			return false, nil, err
 		}
-
-		return false, nil
 	}
-	return true, nil
+	result, rErr := rf.read()
+	if rErr != nil {
+		return err == nil, nil, &ScriptResultError{Err: rErr}
+	}
+	return err == nil, result, nil
 }
 
 func exitCode(exitErr *exec.ExitError) int {
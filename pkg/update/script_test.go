@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"testing"
+	"time"
 
 	"github.com/skycoin/skycoin/src/util/logging"
 	"github.com/stretchr/testify/assert"
@@ -63,3 +64,146 @@ func TestExecuteScript(t *testing.T) {
 		assert.False(t, ok)
 	})
 }
+
+func TestExecuteScript_output(t *testing.T) {
+	fName, rm := prepareScript(t, "echo line1; echo line2 >&2; printf partial")
+	defer rm()
+
+	o := NewOutput()
+	ok, err := ExecuteScript(WithOutput(context.Background(), o),
+		logging.MustGetLogger("output"),
+		exec.Command("/bin/bash", fName))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	lines := o.Lines()
+	require.Len(t, lines, 3)
+	texts := make(map[string]string)
+	for _, l := range lines {
+		texts[l.Text] = l.Source
+	}
+	assert.Equal(t, map[string]string{"line1": "stdout", "line2": "stderr", "partial": "stdout"}, texts)
+}
+
+func TestExecuteScriptResult(t *testing.T) {
+	t.Run("no_result", func(t *testing.T) {
+		fName, rm := prepareScript(t, "exit 0")
+		defer rm()
+
+		ok, result, err := ExecuteScriptResult(context.Background(),
+			logging.MustGetLogger("no_result"),
+			exec.Command("/bin/bash", fName))
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Nil(t, result)
+	})
+
+	t.Run("progress", func(t *testing.T) {
+		// Progress events are captured while the script runs.
+		fName, rm := prepareScript(t, `
+echo '{"progress": "downloading"}' >> "$SWU_RESULT_FILE"
+sleep 1
+echo done
+echo '{"progress": ["verifying", "installing"], "version": "v0.2.0"}' >> "$SWU_RESULT_FILE"
+exit 1`)
+		defer rm()
+
+		o := NewOutput()
+		ok, result, err := ExecuteScriptResult(WithOutput(context.Background(), o),
+			logging.MustGetLogger("progress"),
+			exec.Command("/bin/bash", fName))
+		require.NoError(t, err)
+		assert.False(t, ok)
+		require.NotNil(t, result)
+		assert.Equal(t, "v0.2.0", result.Version)
+
+		var lines []string
+		for _, l := range o.Lines() {
+			lines = append(lines, l.Source+": "+l.Text)
+		}
+		assert.Equal(t, []string{"progress: downloading", "stdout: done", "progress: verifying", "progress: installing"}, lines)
+	})
+
+	t.Run("rewritten", func(t *testing.T) {
+		fName, rm := prepareScript(t, `
+echo '{"progress": ["a", "b"]}' > "$SWU_RESULT_FILE"
+sleep 1
+echo '{"version": "v1"}' > "$SWU_RESULT_FILE"
+sleep 1
+echo '{"progress": "c"}' >> "$SWU_RESULT_FILE"`)
+		defer rm()
+
+		o := NewOutput()
+		ok, result, err := ExecuteScriptResult(WithOutput(context.Background(), o),
+			logging.MustGetLogger("rewritten"),
+			exec.Command("/bin/bash", fName))
+		require.NoError(t, err)
+		assert.True(t, ok)
+		require.NotNil(t, result)
+		assert.Equal(t, "v1", result.Version)
+
+		var texts []string
+		for _, l := range o.Lines() {
+			texts = append(texts, l.Text)
+		}
+		assert.Equal(t, []string{"a", "b", "c"}, texts)
+	})
+
+	t.Run("job progress", func(t *testing.T) {
+		fName, rm := prepareScript(t, `
+echo '{"progress": {"message": "downloading", "percent": 40}}' >> "$SWU_RESULT_FILE"
+echo '{"progress": "verifying"}' >> "$SWU_RESULT_FILE"`)
+		defer rm()
+
+		job := newJob(NewOutput(), "v0.2.0", "", func() {})
+		ok, _, err := ExecuteScriptResult(withJobProgress(context.Background(), job),
+			logging.MustGetLogger("job-progress"),
+			exec.Command("/bin/bash", fName))
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		// Events without a percentage keep the previous one.
+		percent := 40.0
+		assert.Equal(t, &Progress{Message: "verifying", Percent: &percent}, job.Info(false).Progress)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		fName, rm := prepareScript(t, `echo '{"version": ' > "$SWU_RESULT_FILE"`)
+		defer rm()
+
+		ok, _, err := ExecuteScriptResult(context.Background(),
+			logging.MustGetLogger("invalid"),
+			exec.Command("/bin/bash", fName))
+		_, invalid := err.(*ScriptResultError)
+		assert.True(t, invalid, err)
+		assert.True(t, ok)
+
+		// Updaters ignore invalid results.
+		ok, err = ExecuteScript(context.Background(),
+			logging.MustGetLogger("invalid"),
+			exec.Command("/bin/bash", fName))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+}
+
+func TestScriptChecker_result(t *testing.T) {
+	fName, rm := prepareScript(t, `cat > "$SWU_RESULT_FILE" <<EOF
+{
+	"version": "v0.3.0",
+	"timestamp": "2019-04-01T10:00:00Z",
+	"notes": "Fixes.",
+	"artifacts": [{"name": "skywire.tar.gz", "url": "https://dl.example.com/skywire.tar.gz", "checksum": "beef"}]
+}
+EOF`)
+	defer rm()
+
+	c := ServiceConfig{Checker: CheckerConfig{Type: ScriptCheckerType, Interpreter: "/bin/bash", Script: fName}}
+	r, err := NewScriptChecker("srv", c, &ServiceDefaultsConfig{}).Check(context.Background())
+	require.NoError(t, err)
+	assert.True(t, r.HasUpdate)
+	assert.Equal(t, "v0.3.0", r.Version)
+	assert.Equal(t, time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC), r.Timestamp.UTC())
+	assert.Equal(t, "Fixes.", r.Notes)
+	assert.Equal(t, []Artifact{{Name: "skywire.tar.gz", URL: "https://dl.example.com/skywire.tar.gz", Checksum: "beef"}}, r.Artifacts)
+}
@@ -0,0 +1,298 @@
+package update
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version (https://semver.org). Versions may have a "v"
+// prefix, and may omit their minor and patch numbers (which are then zero).
+type Version struct {
+	Major, Minor, Patch uint64
+	Pre                 []string // Pre-release identifiers.
+	Build               string
+
+	parts int // Number of major, minor and patch numbers specified.
+}
+
+// ParseVersion parses a semantic version.
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	rest := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		rest, v.Build = rest[:i], rest[i+1:]
+		if v.Build == "" {
+			return Version{}, fmt.Errorf("invalid version '%s': empty build metadata", s)
+		}
+	}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		var pre string
+		rest, pre = rest[:i], rest[i+1:]
+		v.Pre = strings.Split(pre, ".")
+		for _, id := range v.Pre {
+			if id == "" {
+				return Version{}, fmt.Errorf("invalid version '%s': empty pre-release identifier", s)
+			}
+		}
+	}
+	nums := strings.Split(rest, ".")
+	if len(nums) > 3 {
+		return Version{}, fmt.Errorf("invalid version '%s': too many numbers", s)
+	}
+	for i, dst := range []*uint64{&v.Major, &v.Minor, &v.Patch}[:len(nums)] {
+		n, err := strconv.ParseUint(nums[i], 10, 64)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version '%s': %q is not a number", s, nums[i])
+		}
+		*dst = n
+	}
+	v.parts = len(nums)
+	return v, nil
+}
+
+// String returns the version in canonical form (without a "v" prefix).
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Pre) > 0 {
+		s += "-" + strings.Join(v.Pre, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// IsPrerelease returns whether the version has pre-release identifiers.
+func (v Version) IsPrerelease() bool {
+	return len(v.Pre) > 0
+}
+
+// Compare returns -1, 0 or 1 if v has lower, equal or higher precedence than o.
+// Build metadata is ignored.
+func (v Version) Compare(o Version) int {
+	for _, c := range [][2]uint64{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if c[0] != c[1] {
+			return cmpUint(c[0], c[1])
+		}
+	}
+	// A version without pre-release identifiers has higher precedence.
+	switch {
+	case len(v.Pre) == 0 && len(o.Pre) == 0:
+		return 0
+	case len(v.Pre) == 0:
+		return 1
+	case len(o.Pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.Pre) && i < len(o.Pre); i++ {
+		if c := comparePre(v.Pre[i], o.Pre[i]); c != 0 {
+			return c
+		}
+	}
+	return cmpUint(uint64(len(v.Pre)), uint64(len(o.Pre)))
+}
+
+// comparePre compares pre-release identifiers. Numeric identifiers are
+// compared numerically, and have lower precedence than alphanumeric ones.
+func comparePre(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		return cmpUint(an, bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func cmpUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// next returns the lowest version which is higher than all versions matching
+// the specified numbers of v (for example, 1.3.0 for 1.2).
+func (v Version) next() Version {
+	switch v.parts {
+	case 1:
+		return Version{Major: v.Major + 1, parts: 3}
+	case 2:
+		return Version{Major: v.Major, Minor: v.Minor + 1, parts: 3}
+	default:
+		return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1, parts: 3}
+	}
+}
+
+// comparator matches versions which compare to a version as given by op.
+type comparator struct {
+	op string // One of "=", "!=", ">", ">=", "<", "<=".
+	v  Version
+}
+
+func (c comparator) match(v Version) bool {
+	cmp := v.Compare(c.v)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	default: // "<="
+		return cmp <= 0
+	}
+}
+
+// Constraint restricts versions. It is a list of alternatives separated by
+// "||", each of which is a list of comparators (separated by spaces or commas)
+// which must all match. Comparators are of format '<op><version>', where op is
+// one of "=", "!=", ">", ">=", "<", "<=", "~" or "^". Versions of comparators
+// may be partial (such as '1.2' or '1.2.x'), in which case the omitted numbers
+// match any number.
+//
+// '~1.2.3' matches versions from 1.2.3 up to (but excluding) 1.3.0, and '~1.2'
+// or '~1' match any version of 1.2 or 1. '^1.2.3' matches versions from 1.2.3
+// up to 2.0.0 (or up to 0.3.0 for '^0.2.3').
+//
+// As with ranges of standard semantic versioning, pre-release versions only
+// match an alternative which has a comparator naming a pre-release of the
+// same major, minor and patch numbers, so '<1.0.0' does not match
+// '1.0.0-rc.1', but '>=1.2.0-beta.1' matches '1.2.0-beta.2'.
+type Constraint struct {
+	raw  string
+	alts [][]comparator
+}
+
+// ParseConstraint parses a constraint.
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{raw: s}
+	for _, alt := range strings.Split(s, "||") {
+		var (
+			comps []comparator
+			op    string // Operator separated from its version by a space.
+		)
+		for _, field := range strings.FieldsFunc(alt, func(r rune) bool { return r == ' ' || r == ',' }) {
+			if strings.Trim(field, "=!<>~^") == "" {
+				op += field
+				continue
+			}
+			cs, err := parseComparator(op + field)
+			op = ""
+			if err != nil {
+				return nil, fmt.Errorf("invalid constraint '%s': %s", s, err)
+			}
+			comps = append(comps, cs...)
+		}
+		if len(comps) == 0 || op != "" {
+			return nil, fmt.Errorf("invalid constraint '%s': empty or incomplete alternative", s)
+		}
+		c.alts = append(c.alts, comps)
+	}
+	return c, nil
+}
+
+func parseComparator(s string) ([]comparator, error) {
+	op := s[:len(s)-len(strings.TrimLeft(s, "=!<>~^"))]
+	raw := s[len(op):]
+	for strings.HasSuffix(raw, ".x") || strings.HasSuffix(raw, ".*") {
+		raw = raw[:len(raw)-2]
+	}
+	if raw == "x" || raw == "*" {
+		return []comparator{{">=", Version{}}}, nil
+	}
+	v, err := ParseVersion(raw)
+	if err != nil {
+		return nil, err
+	}
+	partial := v.parts < 3
+	switch op {
+	case "", "=":
+		if partial {
+			return []comparator{{">=", v}, {"<", v.next()}}, nil
+		}
+		return []comparator{{"=", v}}, nil
+	case "!=", "<", ">=":
+		return []comparator{{op, v}}, nil
+	case ">":
+		if partial {
+			return []comparator{{">=", v.next()}}, nil
+		}
+		return []comparator{{">", v}}, nil
+	case "<=":
+		if partial {
+			return []comparator{{"<", v.next()}}, nil
+		}
+		return []comparator{{"<=", v}}, nil
+	case "~":
+		upper := v
+		if v.parts == 3 {
+			upper.parts = 2
+		}
+		return []comparator{{">=", v}, {"<", upper.next()}}, nil
+	case "^":
+		upper := v
+		switch {
+		case v.Major > 0 || v.parts == 1:
+			upper.parts = 1
+		case v.Minor > 0 || v.parts == 2:
+			upper.parts = 2
+		default:
+			upper.parts = 3
+		}
+		return []comparator{{">=", v}, {"<", upper.next()}}, nil
+	default:
+		return nil, fmt.Errorf("invalid operator '%s'", op)
+	}
+}
+
+// Check returns whether v satisfies the constraint.
+func (c *Constraint) Check(v Version) bool {
+	for _, alt := range c.alts {
+		if v.IsPrerelease() && !allowsPrerelease(alt, v) {
+			continue
+		}
+		ok := true
+		for _, comp := range alt {
+			if !comp.match(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsPrerelease returns whether a comparator of alt names a pre-release with
+// the same major, minor and patch numbers as v.
+func allowsPrerelease(alt []comparator, v Version) bool {
+	for _, comp := range alt {
+		if comp.v.IsPrerelease() && comp.v.Major == v.Major &&
+			comp.v.Minor == v.Minor && comp.v.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the constraint as it was parsed.
+func (c *Constraint) String() string {
+	return c.raw
+}
@@ -0,0 +1,109 @@
+package update
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	v, err := ParseVersion("v1.2.3-rc.1+build.5")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), v.Major)
+	assert.Equal(t, uint64(2), v.Minor)
+	assert.Equal(t, uint64(3), v.Patch)
+	assert.Equal(t, []string{"rc", "1"}, v.Pre)
+	assert.Equal(t, "build.5", v.Build)
+	assert.Equal(t, "1.2.3-rc.1+build.5", v.String())
+
+	v, err = ParseVersion("0.2")
+	require.NoError(t, err)
+	assert.Equal(t, "0.2.0", v.String())
+
+	for _, s := range []string{"", "master", "1.2.3.4", "1.x", "1.2.3-", "1.2.3-rc..1", "1.2.3+"} {
+		_, err := ParseVersion(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestVersion_Compare(t *testing.T) {
+	// In ascending order of precedence.
+	versions := []string{
+		"0.1.0",
+		"0.2.0-alpha",
+		"0.2.0-alpha.1",
+		"0.2.0-alpha.beta",
+		"0.2.0-beta.2",
+		"0.2.0-beta.11",
+		"0.2.0-rc.1",
+		"v0.2.0",
+		"0.2.1",
+		"0.10.0",
+		"1.0.0",
+	}
+	for i := range versions {
+		for j := range versions {
+			a, err := ParseVersion(versions[i])
+			require.NoError(t, err)
+			b, err := ParseVersion(versions[j])
+			require.NoError(t, err)
+			assert.Equal(t, cmpUint(uint64(i), uint64(j)), a.Compare(b), "%s <=> %s", versions[i], versions[j])
+		}
+	}
+
+	a, _ := ParseVersion("1.0.0+a") //nolint:errcheck
+	b, _ := ParseVersion("1.0.0+b") //nolint:errcheck
+	assert.Equal(t, 0, a.Compare(b))
+}
+
+func TestConstraint_Check(t *testing.T) {
+	cases := []struct {
+		constraint string
+		match      []string
+		noMatch    []string
+	}{
+		{"~0.2", []string{"0.2.0", "0.2.9"}, []string{"0.1.9", "0.3.0", "1.2.0"}},
+		{"~0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.2.2", "0.3.0"}},
+		{"~1", []string{"1.0.0", "1.9.9"}, []string{"0.9.0", "2.0.0"}},
+		{"^1.2.3", []string{"1.2.3", "1.9.0"}, []string{"1.2.2", "2.0.0"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"<1.0.0", []string{"0.9.9", "v0.1.0"}, []string{"1.0.0", "1.0.1"}},
+		{">=0.2, <0.4", []string{"0.2.0", "0.3.9"}, []string{"0.1.9", "0.4.0"}},
+		{">= 0.2 < 0.4", []string{"0.2.0", "0.3.9"}, []string{"0.1.9", "0.4.0"}},
+		{">0.2", []string{"0.3.0"}, []string{"0.2.9"}},
+		{"<=0.2", []string{"0.2.9"}, []string{"0.3.0"}},
+		{"1.2.x", []string{"1.2.0", "1.2.7"}, []string{"1.3.0"}},
+		{"=1.2.3", []string{"1.2.3"}, []string{"1.2.4"}},
+		{"!=1.2.3", []string{"1.2.4"}, []string{"1.2.3"}},
+		{"~0.1 || >=1.0", []string{"0.1.5", "1.2.0"}, []string{"0.2.0"}},
+		{"*", []string{"0.0.1", "9.9.9"}, []string{"1.0.0-rc.1"}},
+		{"<1.0.0", nil, []string{"1.0.0-rc.1", "0.9.0-beta"}},
+		{">=1.2.0", []string{"1.3.0"}, []string{"1.3.0-beta", "1.2.0-rc.1"}},
+		{">=1.2.0-beta.1", []string{"1.2.0-beta.2", "1.2.0", "1.3.0"}, []string{"1.2.0-alpha", "1.3.0-beta"}},
+		{"^1.2.0-rc.1", []string{"1.2.0-rc.2", "1.4.0"}, []string{"1.4.0-rc.1"}},
+		{">=1.2.0-rc.1 || >=1.0.0", []string{"1.2.0-rc.2", "1.1.0"}, []string{"1.1.0-beta"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.constraint, func(t *testing.T) {
+			c, err := ParseConstraint(tc.constraint)
+			require.NoError(t, err)
+			for _, s := range tc.match {
+				v, err := ParseVersion(s)
+				require.NoError(t, err)
+				assert.True(t, c.Check(v), s)
+			}
+			for _, s := range tc.noMatch {
+				v, err := ParseVersion(s)
+				require.NoError(t, err)
+				assert.False(t, c.Check(v), s)
+			}
+		})
+	}
+
+	for _, s := range []string{"", "~", ">= ", "=>1.0", "~master", "1.0 ||"} {
+		_, err := ParseConstraint(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,303 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skycoin/skywire-updater/pkg/store"
+)
+
+// ErrBackupNotFound occurs when a service has no backup of the version to roll back to.
+var ErrBackupNotFound = errors.New("backup of given version is not found")
+
+// DefaultBackups is the number of previous versions of a service's binaries
+// which are kept as backups.
+const DefaultBackups = 3
+
+// Backup is a backup of the binaries of a previous version of a service.
+type Backup struct {
+	Version   string    `json:"version"`
+	Binaries  []string  `json:"binaries"`
+	CreatedAt time.Time `json:"created_at"`
+	dir       string
+}
+
+// Tag returns the version of the backup ("" if unknown).
+func (b Backup) Tag() string {
+	if b.Version == backupName("") {
+		return ""
+	}
+	return b.Version
+}
+
+// findBackup finds the backup of the given version of a service. If version is
+// empty, the newest backup of a version which is neither the current version
+// nor rolled back from is found.
+func findBackup(binDir, srvName, version string, last store.Update) (*Backup, error) {
+	if binDir == "" {
+		return nil, ErrBackupNotFound
+	}
+	backups, err := listBackups(backupRoot(binDir, srvName))
+	if err != nil {
+		return nil, err
+	}
+	for i, b := range backups {
+		switch {
+		case version != "" && b.Version == backupName(version):
+			return &backups[i], nil
+		case version == "" && b.Tag() != last.Installed() && !last.IsRolledBack(b.Tag()):
+			return &backups[i], nil
+		}
+	}
+	return nil, ErrBackupNotFound
+}
+
+// binSwap stages the binaries built or downloaded by a service's updater in a
+// sibling directory of the bin directory, and swaps them with the live
+// binaries once the updater succeeds. The live binaries are backed up first,
+// so that they can be restored if the swap or the new version fails.
+type binSwap struct {
+	binDir     string
+	stageDir   string
+	backupRoot string // Contains a directory of binaries per backed up version.
+	keep       int
+
+	backupDir string   // Backup made by the swap.
+	swapped   []string // Names of the swapped binaries.
+	added     []string // Names of the swapped binaries which did not exist before.
+}
+
+func newBinSwap(srvName, binDir string, keep int) *binSwap {
+	if keep <= 0 {
+		keep = DefaultBackups
+	}
+	parent, base := filepath.Dir(binDir), filepath.Base(binDir)
+	return &binSwap{
+		binDir:     binDir,
+		stageDir:   filepath.Join(parent, "."+base+".staging", srvName),
+		backupRoot: backupRoot(binDir, srvName),
+		keep:       keep,
+	}
+}
+
+func backupRoot(binDir, srvName string) string {
+	return filepath.Join(filepath.Dir(binDir), "."+filepath.Base(binDir)+".backups", srvName)
+}
+
+// stage prepares an empty staging directory.
+func (s *binSwap) stage() error {
+	if err := os.RemoveAll(s.stageDir); err != nil {
+		return err
+	}
+	return os.MkdirAll(s.stageDir, 0750)
+}
+
+// swap backs up the live binaries which are replaced by staged binaries, and
+// moves the staged binaries into the bin directory. If any binary cannot be
+// swapped, the swapped binaries are restored.
+func (s *binSwap) swap(ctx context.Context, fromVersion string) error {
+	infos, err := ioutil.ReadDir(s.stageDir)
+	if err != nil {
+		return err
+	}
+	var staged []string
+	for _, info := range infos {
+		if info.Mode().IsRegular() {
+			staged = append(staged, info.Name())
+		}
+	}
+	if len(staged) == 0 {
+		progress(ctx, log, "Updater staged no binaries in '%s', nothing to swap.", s.stageDir)
+		return nil
+	}
+
+	if s.backupDir, err = s.backup(fromVersion, staged); err != nil {
+		return fmt.Errorf("failed to back up binaries: %s", err)
+	}
+	if err := os.MkdirAll(s.binDir, 0750); err != nil {
+		return err
+	}
+	for _, name := range staged {
+		dst := filepath.Join(s.binDir, name)
+		_, statErr := os.Stat(dst)
+		if err := os.Rename(filepath.Join(s.stageDir, name), dst); err != nil {
+			err = fmt.Errorf("failed to swap '%s': %s", name, err)
+			if rErr := s.restore(ctx); rErr != nil {
+				return fmt.Errorf("%s (restore also failed: %s)", err, rErr)
+			}
+			return err
+		}
+		s.swapped = append(s.swapped, name)
+		if os.IsNotExist(statErr) {
+			s.added = append(s.added, name)
+		}
+	}
+	progress(ctx, log, "Swapped binaries %v into '%s'.", staged, s.binDir)
+	return nil
+}
+
+// backup links (or copies) the live counterparts of the given binaries into
+// the backup directory of the given version.
+func (s *binSwap) backup(version string, names []string) (string, error) {
+	dir := filepath.Join(s.backupRoot, backupName(version))
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", err
+	}
+	for _, name := range names {
+		src := filepath.Join(s.binDir, name)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := linkOrCopy(src, filepath.Join(dir, name)); err != nil {
+			return "", err
+		}
+	}
+	// Backups are ordered by the modification time of their directories.
+	now := time.Now()
+	return dir, os.Chtimes(dir, now, now)
+}
+
+// restore restores the swapped binaries from the backup, and removes the
+// swapped binaries which did not exist before.
+func (s *binSwap) restore(ctx context.Context) error {
+	added := make(map[string]bool, len(s.added))
+	for _, name := range s.added {
+		added[name] = true
+	}
+	for _, name := range s.swapped {
+		dst := filepath.Join(s.binDir, name)
+		if added[name] {
+			if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err := replaceFile(filepath.Join(s.backupDir, name), dst); err != nil {
+			return fmt.Errorf("failed to restore '%s': %s", name, err)
+		}
+	}
+	progress(ctx, log, "Restored binaries %v from backup '%s'.", s.swapped, s.backupDir)
+	s.swapped, s.added = nil, nil
+	return nil
+}
+
+// cleanup removes the staging directory and the oldest backups.
+func (s *binSwap) cleanup() {
+	if err := os.RemoveAll(s.stageDir); err != nil {
+		log.WithError(err).Warnf("Failed to remove staging directory '%s'.", s.stageDir)
+	}
+	backups, err := listBackups(s.backupRoot)
+	if err != nil {
+		log.WithError(err).Warnf("Failed to list backups in '%s'.", s.backupRoot)
+		return
+	}
+	for _, b := range backups[minInt(len(backups), s.keep):] {
+		if err := os.RemoveAll(b.dir); err != nil {
+			log.WithError(err).Warnf("Failed to remove old backup '%s'.", b.dir)
+		}
+	}
+}
+
+// listBackups lists the backups in the given directory, newest first.
+func listBackups(root string) ([]Backup, error) {
+	infos, err := ioutil.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var backups []Backup
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		b := Backup{
+			Version:   info.Name(),
+			CreatedAt: info.ModTime(),
+			dir:       filepath.Join(root, info.Name()),
+		}
+		bins, err := ioutil.ReadDir(b.dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, bin := range bins {
+			b.Binaries = append(b.Binaries, bin.Name())
+		}
+		backups = append(backups, b)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// backupName returns the name of the backup directory of a version.
+func backupName(version string) string {
+	if version == "" {
+		return "unknown"
+	}
+	return strings.NewReplacer("/", "_", string(filepath.Separator), "_").Replace(version)
+}
+
+// replaceFile links (or copies) src to a temporary file next to dst, and
+// renames it over dst.
+func replaceFile(src, dst string) error {
+	tmp := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp")
+	if err := linkOrCopy(src, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		_ = os.Remove(tmp) //nolint:errcheck
+		return err
+	}
+	return nil
+}
+
+func linkOrCopy(src, dst string) error {
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return writeBinary(dst, in)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+type binDirKey struct{}
+
+// withBinDir returns a context which makes updaters install binaries into dir
+// instead of the service's bin directory.
+func withBinDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, binDirKey{}, dir)
+}
+
+// binDirFromContext returns the directory updaters should install binaries into.
+func binDirFromContext(ctx context.Context, binDir string) string {
+	if dir, ok := ctx.Value(binDirKey{}).(string); ok {
+		return dir
+	}
+	return binDir
+}
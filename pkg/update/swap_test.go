@@ -0,0 +1,204 @@
+package update
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// prepareBinDir creates a bin directory (within a temporary parent directory)
+// which contains the given binaries.
+func prepareBinDir(t *testing.T, bins map[string]string) (string, func()) {
+	parent, err := ioutil.TempDir(os.TempDir(), "")
+	require.NoError(t, err)
+	binDir := filepath.Join(parent, "bin")
+	require.NoError(t, os.MkdirAll(binDir, 0750))
+	for name, content := range bins {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(binDir, name), []byte(content), 0750))
+	}
+	rm := func() {
+		require.NoError(t, os.RemoveAll(parent))
+	}
+	return binDir, rm
+}
+
+func readBins(t *testing.T, dir string) map[string]string {
+	infos, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	bins := make(map[string]string)
+	for _, info := range infos {
+		raw, err := ioutil.ReadFile(filepath.Join(dir, info.Name()))
+		require.NoError(t, err)
+		bins[info.Name()] = string(raw)
+	}
+	return bins
+}
+
+func TestBinSwap(t *testing.T) {
+	binDir, rm := prepareBinDir(t, map[string]string{"run": "v1", "other": "other"})
+	defer rm()
+	ctx := context.Background()
+
+	s := newBinSwap("srv", binDir, 2)
+	require.NoError(t, s.stage())
+	require.NoError(t, ioutil.WriteFile(filepath.Join(s.stageDir, "run"), []byte("v2"), 0750))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(s.stageDir, "cli"), []byte("v2"), 0750))
+	require.NoError(t, s.swap(ctx, "v1"))
+	assert.Equal(t, map[string]string{"run": "v2", "cli": "v2", "other": "other"}, readBins(t, binDir))
+	assert.Equal(t, map[string]string{"run": "v1"}, readBins(t, s.backupDir))
+
+	require.NoError(t, s.restore(ctx))
+	assert.Equal(t, map[string]string{"run": "v1", "other": "other"}, readBins(t, binDir))
+	s.cleanup()
+	_, err := os.Stat(s.stageDir)
+	assert.True(t, os.IsNotExist(err))
+
+	// Only the newest backups are kept.
+	for _, version := range []string{"v2", "v3", "v4"} {
+		s := newBinSwap("srv", binDir, 2)
+		require.NoError(t, s.stage())
+		require.NoError(t, ioutil.WriteFile(filepath.Join(s.stageDir, "run"), []byte("new"), 0750))
+		require.NoError(t, s.swap(ctx, version))
+		s.cleanup()
+	}
+	backups, err := listBackups(backupRoot(binDir, "srv"))
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	assert.Equal(t, "v4", backups[0].Version)
+	assert.Equal(t, "v3", backups[1].Version)
+	assert.Equal(t, []string{"run"}, backups[0].Binaries)
+}
+
+func TestManager_install(t *testing.T) {
+	binDir, rmBin := prepareBinDir(t, map[string]string{"run": "v1"})
+	defer rmBin()
+	good, rmGood := prepareScript(t, `echo -n "${SWU_TO_VERSION}" > "${SWU_BIN_DIR}/run"`)
+	defer rmGood()
+	bad, rmBad := prepareScript(t, `echo -n "broken" > "${SWU_BIN_DIR}/run"; exit 1`)
+	defer rmBad()
+	db, rmDB := prepareDB(t)
+	defer rmDB()
+
+	conf := NewConfig(os.TempDir(), os.TempDir())
+	conf.Services.Services["good"] = &ServiceConfig{
+		BinDir:        binDir,
+		CheckInterval: -1,
+		Checker:       CheckerConfig{Type: ScriptCheckerType},
+		Updater:       UpdaterConfig{Type: ScriptUpdaterType, Interpreter: "/bin/bash", Script: good},
+	}
+	conf.Services.Services["bad"] = &ServiceConfig{
+		BinDir:        binDir,
+		CheckInterval: -1,
+		Checker:       CheckerConfig{Type: ScriptCheckerType},
+		Updater:       UpdaterConfig{Type: ScriptUpdaterType, Interpreter: "/bin/bash", Script: bad},
+	}
+	m := NewManager(db, conf)
+	defer func() {
+		require.NoError(t, m.Close())
+	}()
+
+	// A failed update leaves the live binaries untouched.
+	updated, err := m.Update(context.Background(), "bad", "v2", UpdateOptions{})
+	require.NoError(t, err)
+	assert.False(t, updated)
+	assert.Equal(t, map[string]string{"run": "v1"}, readBins(t, binDir))
+
+	updated, err = m.Update(context.Background(), "good", "v2", UpdateOptions{})
+	require.NoError(t, err)
+	assert.True(t, updated)
+	assert.Equal(t, map[string]string{"run": "v2"}, readBins(t, binDir))
+
+	backups, err := listBackups(backupRoot(binDir, "good"))
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.Equal(t, "unknown", backups[0].Version)
+	assert.Equal(t, map[string]string{"run": "v1"}, readBins(t, backups[0].dir))
+}
+
+func TestManager_Rollback(t *testing.T) {
+	binDir, rmBin := prepareBinDir(t, map[string]string{"run": "v1"})
+	defer rmBin()
+	update, rmUpdate := prepareScript(t, `echo -n "${SWU_TO_VERSION}" > "${SWU_BIN_DIR}/run"`)
+	defer rmUpdate()
+	db, rmDB := prepareDB(t)
+	defer rmDB()
+
+	conf := NewConfig(os.TempDir(), os.TempDir())
+	conf.Services.Services["srv"] = &ServiceConfig{
+		BinDir:        binDir,
+		CheckInterval: -1,
+		Checker:       CheckerConfig{Type: ScriptCheckerType},
+		Updater:       UpdaterConfig{Type: ScriptUpdaterType, Interpreter: "/bin/bash", Script: update},
+	}
+	m := NewManager(db, conf)
+	defer func() {
+		require.NoError(t, m.Close())
+	}()
+	ctx := context.Background()
+
+	for _, version := range []string{"v2", "v3"} {
+		updated, err := m.Update(ctx, "srv", version, UpdateOptions{})
+		require.NoError(t, err)
+		require.True(t, updated)
+	}
+
+	restored, err := m.Rollback(ctx, "srv", "", UpdateOptions{Trigger: "test"})
+	require.NoError(t, err)
+	assert.Equal(t, "v2", restored)
+	assert.Equal(t, map[string]string{"run": "v2"}, readBins(t, binDir))
+	last := db.ServiceLastUpdate("srv")
+	assert.Equal(t, "v2", last.Tag)
+	assert.True(t, last.IsRolledBack("v3"))
+
+	// Neither the current version nor the version rolled back from are
+	// restored again.
+	restored, err = m.Rollback(ctx, "srv", "", UpdateOptions{Trigger: "test"})
+	require.NoError(t, err)
+	assert.Equal(t, "", restored)
+	assert.Equal(t, map[string]string{"run": "v1"}, readBins(t, binDir))
+
+	_, err = m.Rollback(ctx, "srv", "v9", UpdateOptions{Trigger: "test"})
+	assert.Equal(t, ErrBackupNotFound, err)
+
+	// Updating to a rolled back version explicitly allows it again.
+	updated, err := m.Update(ctx, "srv", "v3", UpdateOptions{})
+	require.NoError(t, err)
+	require.True(t, updated)
+	assert.False(t, db.ServiceLastUpdate("srv").IsRolledBack("v3"))
+
+	history, err := m.History("srv")
+	require.NoError(t, err)
+	require.Len(t, history, 5)
+	assert.True(t, history[2].Rollback)
+	assert.Equal(t, "v3", history[2].FromVersion)
+	assert.Equal(t, "v2", history[2].ToVersion)
+
+	// Rollbacks are subject to the policy and maintenance windows of the service.
+	srv := m.services["srv"]
+	srv.Policy = PolicyConfig{Mode: PinnedPolicy, Pinned: "v3"}
+	_, err = m.Rollback(ctx, "srv", "v2", UpdateOptions{})
+	assert.Equal(t, ErrServicePinned, err)
+
+	now := time.Now().UTC()
+	srv.Policy = PolicyConfig{Mode: NotifyOnlyPolicy}
+	srv.Maintenance = MaintenanceConfig{
+		Timezone: "UTC",
+		Windows: []MaintenanceWindow{{
+			Start: now.Add(2 * time.Hour).Format("15:04"),
+			End:   now.Add(3 * time.Hour).Format("15:04"),
+		}},
+	}
+	_, err = m.Rollback(ctx, "srv", "v2", UpdateOptions{})
+	assert.IsType(t, &WindowError{}, err)
+	assert.Equal(t, map[string]string{"run": "v3"}, readBins(t, binDir))
+
+	restored, err = m.Rollback(ctx, "srv", "v2", UpdateOptions{Force: true})
+	require.NoError(t, err)
+	assert.Equal(t, "v2", restored)
+}
@@ -0,0 +1,267 @@
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/util/logging"
+)
+
+// DefaultSignaturesPattern is the name of the detached signature asset of a
+// signed file, where {file} is the name of the signed file.
+const DefaultSignaturesPattern = "{file}.sig"
+
+var auditLog = logging.MustGetLogger("audit")
+
+// SignatureError occurs when a release file is not signed by enough trusted keys.
+type SignatureError struct {
+	File      string
+	Signers   int // Number of distinct trusted keys with valid signatures.
+	Threshold int
+	Reason    string
+}
+
+// Error implements error.
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("signature verification of '%s' failed (%d of %d required trusted signatures): %s",
+		e.File, e.Signers, e.Threshold, e.Reason)
+}
+
+// TrustConfig configures the skycoin cipher public keys which are trusted to
+// sign the releases of a service. Releases are not verified if no keys are
+// defined.
+type TrustConfig struct {
+	PubKeys    []string `yaml:"pubkeys,omitempty"`    // Hex-encoded public keys.
+	Threshold  int      `yaml:"threshold,omitempty"`  // Number of distinct trusted keys which must sign (1 if unspecified).
+	Signatures string   `yaml:"signatures,omitempty"` // Signature asset name, with a {file} placeholder.
+}
+
+// Enabled returns whether releases are to be verified.
+func (t TrustConfig) Enabled() bool {
+	return len(t.PubKeys) > 0
+}
+
+// signaturesName returns the name of the signature asset of the given file.
+func (t TrustConfig) signaturesName(file string) string {
+	pattern := t.Signatures
+	if pattern == "" {
+		pattern = DefaultSignaturesPattern
+	}
+	return strings.Replace(pattern, "{file}", file, -1)
+}
+
+func (t TrustConfig) parse() (map[cipher.PubKey]bool, error) {
+	keys := make(map[cipher.PubKey]bool, len(t.PubKeys))
+	for _, s := range t.PubKeys {
+		pk, err := cipher.PubKeyFromHex(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted public key '%s': %s", s, err)
+		}
+		keys[pk] = true
+	}
+	return keys, nil
+}
+
+// Verify checks that data is signed by at least the threshold of distinct
+// trusted keys. Signatures are hex-encoded skycoin cipher signatures of the
+// SHA-256 hash of data, one per line. Each verification is recorded in the
+// audit log.
+func (t TrustConfig) Verify(srvName, file string, data, sigs []byte) error {
+	hash := cipher.SumSHA256(data)
+	l := auditLog.
+		WithField("service", srvName).
+		WithField("asset", file).
+		WithField("sha256", hash.Hex())
+
+	signers, err := t.verify(file, hash, sigs)
+	hexSigners := make([]string, len(signers))
+	for i, pk := range signers {
+		hexSigners[i] = pk.Hex()
+	}
+	l = l.WithField("signers", hexSigners).WithField("threshold", t.threshold())
+	if err != nil {
+		l.WithError(err).Error("Release signature verification failed.")
+		return err
+	}
+	l.Info("Release signature verification succeeded.")
+	return nil
+}
+
+func (t TrustConfig) verify(file string, hash cipher.SHA256, sigs []byte) ([]cipher.PubKey, error) {
+	sigErr := &SignatureError{File: file, Threshold: t.threshold()}
+	keys, err := t.parse()
+	if err != nil {
+		sigErr.Reason = err.Error()
+		return nil, sigErr
+	}
+
+	var signers []cipher.PubKey
+	seen := make(map[cipher.PubKey]bool)
+	sc := bufio.NewScanner(bytes.NewReader(sigs))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sig, err := cipher.SigFromHex(line)
+		if err != nil {
+			continue
+		}
+		pk, err := cipher.PubKeyFromSig(sig, hash)
+		if err != nil || !keys[pk] || seen[pk] {
+			continue
+		}
+		if err := cipher.VerifyPubKeySignedHash(pk, sig, hash); err != nil {
+			continue
+		}
+		seen[pk] = true
+		signers = append(signers, pk)
+	}
+	if err := sc.Err(); err != nil {
+		sigErr.Reason = err.Error()
+		return signers, sigErr
+	}
+
+	sigErr.Signers = len(signers)
+	if len(signers) < sigErr.Threshold {
+		sigErr.Reason = "not enough valid signatures by trusted keys"
+		return signers, sigErr
+	}
+	return signers, nil
+}
+
+func (t TrustConfig) threshold() int {
+	if t.Threshold <= 0 {
+		return 1
+	}
+	return t.Threshold
+}
+
+// verifyStaged verifies the binaries staged in dir against the trusted keys,
+// before they are swapped in. Each binary needs to be staged along with its
+// detached signatures (named by the signatures pattern), unless the updater
+// verified it itself. The signature files are removed from dir, so that they
+// are not swapped in.
+func (t TrustConfig) verifyStaged(ctx context.Context, srvName, dir string, verified *verifiedBins) error {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	staged := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		if info.Mode().IsRegular() {
+			staged[info.Name()] = true
+		}
+	}
+	sigFiles := make(map[string]bool)
+	for name := range staged {
+		if sigName := t.signaturesName(name); sigName != name && staged[sigName] {
+			sigFiles[sigName] = true
+		}
+	}
+
+	for name := range staged {
+		if sigFiles[name] || verified.has(name) {
+			continue
+		}
+		sigName := t.signaturesName(name)
+		if !sigFiles[sigName] {
+			return &SignatureError{
+				File:      name,
+				Threshold: t.threshold(),
+				Reason:    fmt.Sprintf("no signatures staged as '%s'", sigName),
+			}
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, name)) //nolint:gosec
+		if err != nil {
+			return err
+		}
+		sigs, err := ioutil.ReadFile(filepath.Join(dir, sigName)) //nolint:gosec
+		if err != nil {
+			return err
+		}
+		if err := t.Verify(srvName, name, data, sigs); err != nil {
+			return err
+		}
+		progress(ctx, log, "Verified signatures of staged '%s' by trusted keys.", name)
+	}
+	for name := range sigFiles {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifiedBins records the staged binaries which an updater verified against
+// the trusted keys itself.
+type verifiedBins struct {
+	names map[string]bool
+	mu    sync.Mutex
+}
+
+func (v *verifiedBins) has(name string) bool {
+	if v == nil {
+		return false
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.names[name]
+}
+
+type verifiedBinsKey struct{}
+
+// withVerifiedBins returns a context in which updaters can record the staged
+// binaries they verified, with markVerified.
+func withVerifiedBins(ctx context.Context) (context.Context, *verifiedBins) {
+	v := &verifiedBins{names: make(map[string]bool)}
+	return context.WithValue(ctx, verifiedBinsKey{}, v), v
+}
+
+// markVerified records that the given staged binary was verified against the
+// trusted keys by the updater.
+func markVerified(ctx context.Context, name string) {
+	if v, ok := ctx.Value(verifiedBinsKey{}).(*verifiedBins); ok {
+		v.mu.Lock()
+		v.names[name] = true
+		v.mu.Unlock()
+	}
+}
+
+// verifiesTrust returns whether the releases of the service can be verified
+// against trusted keys: either by the manager, which verifies the binaries
+// staged by any updater before they are swapped in, or by the updater itself.
+func verifiesTrust(sc *ServiceConfig) bool {
+	return sc.BinDir != "" || sc.Updater.Type == GithubReleaseAssetUpdaterType
+}
+
+// Checks for errors and fills unspecified fields with default values. The
+// default keys are only inherited by services whose releases can be verified.
+func processTrustConfig(t *TrustConfig, d *TrustConfig, sc *ServiceConfig) error {
+	if len(t.PubKeys) == 0 && verifiesTrust(sc) {
+		*t = *d
+	}
+	if !t.Enabled() {
+		return nil
+	}
+	keys, err := t.parse()
+	if err != nil {
+		return err
+	}
+	if t.Threshold < 0 {
+		return errors.New("trust.threshold cannot be negative")
+	}
+	if t.threshold() > len(keys) {
+		return fmt.Errorf("trust.threshold (%d) exceeds the number of trusted keys (%d)", t.threshold(), len(keys))
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package update
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signData(t *testing.T, data []byte, keys ...cipher.SecKey) []byte {
+	var sigs []string
+	for _, sk := range keys {
+		sig, err := cipher.SignHash(cipher.SumSHA256(data), sk)
+		require.NoError(t, err)
+		sigs = append(sigs, sig.Hex())
+	}
+	return []byte(strings.Join(sigs, "\n") + "\n")
+}
+
+func TestTrustConfig_Verify(t *testing.T) {
+	pk1, sk1 := cipher.GenerateKeyPair()
+	pk2, sk2 := cipher.GenerateKeyPair()
+	_, sk3 := cipher.GenerateKeyPair()
+	data := []byte("checksums")
+
+	cases := []struct {
+		name      string
+		trust     TrustConfig
+		sigs      []byte
+		wantValid int
+		wantErr   bool
+	}{
+		{
+			name:  "single key",
+			trust: TrustConfig{PubKeys: []string{pk1.Hex()}},
+			sigs:  signData(t, data, sk1),
+		},
+		{
+			name:  "rotated key",
+			trust: TrustConfig{PubKeys: []string{pk1.Hex(), pk2.Hex()}},
+			sigs:  signData(t, data, sk2),
+		},
+		{
+			name:  "threshold met",
+			trust: TrustConfig{PubKeys: []string{pk1.Hex(), pk2.Hex()}, Threshold: 2},
+			sigs:  signData(t, data, sk3, sk2, sk1),
+		},
+		{
+			name:      "threshold not met by duplicate signatures",
+			trust:     TrustConfig{PubKeys: []string{pk1.Hex(), pk2.Hex()}, Threshold: 2},
+			sigs:      signData(t, data, sk1, sk1),
+			wantValid: 1,
+			wantErr:   true,
+		},
+		{
+			name:    "untrusted key",
+			trust:   TrustConfig{PubKeys: []string{pk1.Hex()}},
+			sigs:    signData(t, data, sk3),
+			wantErr: true,
+		},
+		{
+			name:    "signature of other data",
+			trust:   TrustConfig{PubKeys: []string{pk1.Hex()}},
+			sigs:    signData(t, []byte("other"), sk1),
+			wantErr: true,
+		},
+		{
+			name:    "garbage",
+			trust:   TrustConfig{PubKeys: []string{pk1.Hex()}},
+			sigs:    []byte("not a signature\n"),
+			wantErr: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.trust.Verify("srv", "checksums.txt", data, tc.sigs)
+			if !tc.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+			sigErr, ok := err.(*SignatureError)
+			require.True(t, ok, err)
+			assert.Equal(t, tc.wantValid, sigErr.Signers)
+			assert.Equal(t, tc.trust.threshold(), sigErr.Threshold)
+		})
+	}
+}
+
+func TestProcessTrustConfig(t *testing.T) {
+	pk1, _ := cipher.GenerateKeyPair()
+	pk2, _ := cipher.GenerateKeyPair()
+	d := TrustConfig{PubKeys: []string{pk1.Hex()}}
+
+	asset := &ServiceConfig{Updater: UpdaterConfig{Type: GithubReleaseAssetUpdaterType}}
+	staged := &ServiceConfig{BinDir: "/usr/local/bin", Updater: UpdaterConfig{Type: ScriptUpdaterType}}
+	script := &ServiceConfig{Updater: UpdaterConfig{Type: ScriptUpdaterType}}
+
+	tc := TrustConfig{}
+	require.NoError(t, processTrustConfig(&tc, &d, asset))
+	assert.Equal(t, d, tc)
+
+	// The binaries staged by any updater are verified.
+	tc = TrustConfig{}
+	require.NoError(t, processTrustConfig(&tc, &d, staged))
+	assert.Equal(t, d, tc)
+
+	// Services whose releases cannot be verified do not inherit the default keys.
+	tc = TrustConfig{}
+	require.NoError(t, processTrustConfig(&tc, &d, script))
+	assert.False(t, tc.Enabled())
+
+	tc = TrustConfig{PubKeys: []string{pk1.Hex(), pk2.Hex()}, Threshold: 3}
+	assert.Error(t, processTrustConfig(&tc, &d, asset))
+
+	tc = TrustConfig{PubKeys: []string{"invalid"}}
+	assert.Error(t, processTrustConfig(&tc, &d, asset))
+}
+
+func TestManager_install_trust(t *testing.T) {
+	pk, sk := cipher.GenerateKeyPair()
+	_, untrusted := cipher.GenerateKeyPair()
+	binDir, rmBin := prepareBinDir(t, map[string]string{"run": "v1"})
+	defer rmBin()
+	sigs, err := ioutil.TempDir(os.TempDir(), "sigs")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(sigs))
+	}()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sigs, "v2"), signData(t, []byte("v2"), sk), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(sigs, "v3"), signData(t, []byte("v3"), untrusted), 0600))
+
+	// Stages the binary of the version, along with its signatures (if any).
+	update, rmUpdate := prepareScript(t, `echo -n "${SWU_TO_VERSION}" > "${SWU_BIN_DIR}/run"
+if [[ -f "`+sigs+`/${SWU_TO_VERSION}" ]]; then
+  cp "`+sigs+`/${SWU_TO_VERSION}" "${SWU_BIN_DIR}/run.sig"
+fi`)
+	defer rmUpdate()
+	db, rmDB := prepareDB(t)
+	defer rmDB()
+
+	conf := NewConfig(os.TempDir(), os.TempDir())
+	conf.Services.Services["srv"] = &ServiceConfig{
+		BinDir:        binDir,
+		CheckInterval: -1,
+		Trust:         TrustConfig{PubKeys: []string{pk.Hex()}},
+		Checker:       CheckerConfig{Type: ScriptCheckerType},
+		Updater:       UpdaterConfig{Type: ScriptUpdaterType, Interpreter: "/bin/bash", Script: update},
+	}
+	m := NewManager(db, conf)
+	defer func() {
+		require.NoError(t, m.Close())
+	}()
+
+	// Signed binaries are swapped in, without their signatures.
+	updated, err := m.Update(context.Background(), "srv", "v2", UpdateOptions{})
+	require.NoError(t, err)
+	assert.True(t, updated)
+	assert.Equal(t, map[string]string{"run": "v2"}, readBins(t, binDir))
+
+	// Binaries which are not signed by trusted keys are not.
+	for _, version := range []string{"v3", "v4"} {
+		updated, err = m.Update(context.Background(), "srv", version, UpdateOptions{})
+		assert.False(t, updated)
+		_, ok := err.(*SignatureError)
+		assert.True(t, ok, err)
+		assert.Equal(t, map[string]string{"run": "v2"}, readBins(t, binDir))
+	}
+}
@@ -13,10 +13,14 @@ type UpdaterType string
 const (
 	// ScriptUpdaterType represents the script updater type.
 	ScriptUpdaterType = UpdaterType("script")
+
+	// GithubReleaseAssetUpdaterType represents the github release asset updater type.
+	GithubReleaseAssetUpdaterType = UpdaterType("github-release-asset")
 )
 
 var updaterTypes = []UpdaterType{
 	ScriptUpdaterType,
+	GithubReleaseAssetUpdaterType,
 }
 
 // Updater updates a given service.
@@ -29,6 +33,8 @@ func NewUpdater(srvName string, c ServiceConfig, d *ServiceDefaultsConfig) Updat
 	switch c.Updater.Type {
 	case ScriptUpdaterType:
 		return NewScriptUpdater(srvName, c, d)
+	case GithubReleaseAssetUpdaterType:
+		return NewGithubAssetUpdater(srvName, c)
 	default:
 		log.Fatalf("invalid updater type '%s' at 'services[%s].updater.type' when expecting: %v",
 			c.Updater.Type, updaterTypes)
@@ -53,10 +59,14 @@ func NewScriptUpdater(srvName string, c ServiceConfig, d *ServiceDefaultsConfig)
 }
 
 // Update updates the given service to specified version.
+// The script obtains the directory to install binaries into from SWU_BIN_DIR,
+// which is the staging directory of the update when run by the Manager.
 func (cu *ScriptUpdater) Update(ctx context.Context, version string) (bool, error) {
-	update := cu.c.Updater
+	c := cu.c
+	c.BinDir = binDirFromContext(ctx, c.BinDir)
+	update := c.Updater
 	cmd := exec.Command(update.Interpreter, append([]string{update.Script}, update.Args...)...) //nolint:gosec
-	cmd.Env = UpdaterEnvs(cu.d, &cu.c, version)
+	cmd.Env = UpdaterEnvs(cu.d, &c, version)
 
 	return ExecuteScript(ctx, cu.log, cmd)
 }
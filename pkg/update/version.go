@@ -0,0 +1,215 @@
+package update
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+)
+
+// VersionDetectType determines how the installed version of a service is
+// detected.
+type VersionDetectType string
+
+const (
+	// CommandVersionDetect runs a command (such as '<binary> --version') and
+	// extracts the version from its output.
+	CommandVersionDetect = VersionDetectType("command")
+
+	// FileVersionDetect reads the version from a file.
+	FileVersionDetect = VersionDetectType("file")
+
+	// ScriptVersionDetect runs a script which outputs the version.
+	ScriptVersionDetect = VersionDetectType("script")
+)
+
+var versionDetectTypes = []VersionDetectType{
+	CommandVersionDetect,
+	FileVersionDetect,
+	ScriptVersionDetect,
+}
+
+// Default version detection values.
+const (
+	DefaultVersionRegex   = `v?[0-9]+(?:\.[0-9]+){1,2}(?:-[0-9A-Za-z.-]+)?(?:\+[0-9A-Za-z.-]+)?`
+	DefaultVersionFile    = "VERSION"
+	DefaultVersionTimeout = 10 * time.Second
+)
+
+// VersionDetectConfig configures how the installed version of a service is
+// detected. The version is not detected if no type is specified.
+type VersionDetectConfig struct {
+	Type    VersionDetectType `yaml:"type,omitempty"`
+	Regex   string            `yaml:"regex,omitempty"`   // Extracts the version from the output (the first group if any, or the whole match).
+	Timeout time.Duration     `yaml:"timeout,omitempty"` // Timeout of commands and scripts.
+
+	// command version detection fields:
+	Command []string `yaml:"command,omitempty"` // Command to run ('{bin-dir}/{main-process} --version' if unspecified).
+
+	// file version detection fields:
+	File string `yaml:"file,omitempty"` // File to read ('{bin-dir}/VERSION' if unspecified).
+
+	// script version detection fields:
+	Interpreter string   `yaml:"interpreter,omitempty"`
+	Script      string   `yaml:"script,omitempty"`
+	Args        []string `yaml:"args,omitempty"`
+	Envs        []string `yaml:"envs,omitempty"`
+}
+
+// Enabled returns whether the installed version is detected.
+func (v VersionDetectConfig) Enabled() bool {
+	return v.Type != ""
+}
+
+// VersionDetector detects the installed version of a service.
+type VersionDetector struct {
+	c   ServiceConfig
+	d   *ServiceDefaultsConfig
+	re  *regexp.Regexp // Extracts the version (the whole output is used if nil).
+	log *logging.Logger
+}
+
+// NewVersionDetector creates a new VersionDetector.
+func NewVersionDetector(srvName string, c ServiceConfig, d *ServiceDefaultsConfig) *VersionDetector {
+	vd := &VersionDetector{
+		c:   c,
+		d:   d,
+		log: logging.MustGetLogger("version-detect." + srvName),
+	}
+	if vd.c.VersionDetect.Timeout == 0 {
+		vd.c.VersionDetect.Timeout = DefaultVersionTimeout
+	}
+	regex := c.VersionDetect.Regex
+	if regex == "" && c.VersionDetect.Type == CommandVersionDetect {
+		regex = DefaultVersionRegex
+	}
+	if regex != "" {
+		re, err := regexp.Compile(regex)
+		if err != nil {
+			vd.log.WithError(err).Fatal("Invalid version regex.")
+		}
+		vd.re = re
+	}
+	return vd
+}
+
+// Detect detects the installed version.
+func (vd *VersionDetector) Detect(ctx context.Context) (string, error) {
+	v := vd.c.VersionDetect
+	var out []byte
+	var err error
+	switch v.Type {
+	case CommandVersionDetect:
+		if len(v.Command) == 0 {
+			return "", errors.New("no version command defined")
+		}
+		out, err = vd.run(ctx, v.Command[0], v.Command[1:]...)
+	case FileVersionDetect:
+		out, err = ioutil.ReadFile(v.File)
+	case ScriptVersionDetect:
+		out, err = vd.run(ctx, v.Interpreter, append([]string{v.Script}, v.Args...)...)
+	default:
+		err = fmt.Errorf("invalid version detection type '%s'", v.Type)
+	}
+	if err != nil {
+		return "", err
+	}
+	version, err := vd.extract(out)
+	if err != nil {
+		return "", err
+	}
+	progress(ctx, vd.log, "Detected installed version '%s'.", version)
+	return version, nil
+}
+
+// run runs a command and returns its stdout.
+func (vd *VersionDetector) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, vd.c.VersionDetect.Timeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...) //nolint:gosec
+	cmd.Env = VersionDetectEnvs(vd.d, &vd.c)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %s: %s", filepath.Base(name), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// extract extracts the version from the output of a command, script or file.
+func (vd *VersionDetector) extract(out []byte) (string, error) {
+	if vd.re == nil {
+		if version := strings.TrimSpace(string(out)); version != "" {
+			return version, nil
+		}
+		return "", errors.New("no version found in empty output")
+	}
+	m := vd.re.FindSubmatch(out)
+	switch {
+	case m == nil:
+		return "", fmt.Errorf("no version matching '%s' found in output %q", vd.re, strings.TrimSpace(string(out)))
+	case len(m) > 1:
+		return string(m[1]), nil
+	default:
+		return string(m[0]), nil
+	}
+}
+
+// Checks for errors and fills unspecified fields with default values.
+func processVersionDetectConfig(v *VersionDetectConfig, sc *ServiceConfig, scriptsPath string, d *ServiceDefaultsConfig) error {
+	if !v.Enabled() {
+		return nil
+	}
+	if v.Timeout == 0 {
+		v.Timeout = DefaultVersionTimeout
+	}
+	if v.Timeout < 0 {
+		return errors.New("version-detect.timeout cannot be negative")
+	}
+	if v.Regex != "" {
+		if _, err := regexp.Compile(v.Regex); err != nil {
+			return fmt.Errorf("version-detect.regex is invalid: %s", err)
+		}
+	}
+	switch v.Type {
+	case CommandVersionDetect:
+		if len(v.Command) == 0 {
+			if sc.BinDir == "" || sc.MainProcess == "" {
+				return errors.New("version-detect.command, or bin-dir and main-process need to be defined")
+			}
+			v.Command = []string{filepath.Join(sc.BinDir, sc.MainProcess), "--version"}
+		}
+	case FileVersionDetect:
+		if v.File == "" {
+			if sc.BinDir == "" {
+				return errors.New("version-detect.file or bin-dir needs to be defined")
+			}
+			v.File = filepath.Join(sc.BinDir, DefaultVersionFile)
+		}
+	case ScriptVersionDetect:
+		if v.Interpreter == "" {
+			v.Interpreter = d.Interpreter
+		}
+		if v.Script == "" {
+			return errors.New("version-detect.script needs to be defined")
+		}
+		if scriptsPath != "" {
+			v.Script = filepath.Join(scriptsPath, v.Script)
+		}
+		if _, err := os.Stat(v.Script); err != nil {
+			return fmt.Errorf("version-detect.script cannot be accessed: %s", err.Error())
+		}
+	default:
+		return fmt.Errorf("invalid version-detect.type '%s' when expecting: %v", v.Type, versionDetectTypes)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package update
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionDetector_Detect(t *testing.T) {
+	binDir, rmBin := prepareBinDir(t, map[string]string{
+		"VERSION": "v0.2.1\n",
+		"node":    "#!/bin/sh\necho \"skywire-node version v0.2.1+4f2c9a1 (built with go1.12)\"\n",
+	})
+	defer rmBin()
+	script, rmScript := prepareScript(t, `echo "${SWU_MAIN_PROCESS}-1.4"`)
+	defer rmScript()
+
+	cases := []struct {
+		name    string
+		detect  VersionDetectConfig
+		version string
+	}{
+		{"command", VersionDetectConfig{Type: CommandVersionDetect}, "v0.2.1+4f2c9a1"},
+		{"command with regex", VersionDetectConfig{Type: CommandVersionDetect, Regex: `version v([0-9.]+)`}, "0.2.1"},
+		{"command not matching", VersionDetectConfig{Type: CommandVersionDetect, Regex: `release ([0-9.]+)`}, ""},
+		{"command failing", VersionDetectConfig{Type: CommandVersionDetect, Command: []string{"/bin/sh", "-c", "exit 1"}}, ""},
+		{"file", VersionDetectConfig{Type: FileVersionDetect}, "v0.2.1"},
+		{"file missing", VersionDetectConfig{Type: FileVersionDetect, File: filepath.Join(binDir, "missing")}, ""},
+		{"script", VersionDetectConfig{Type: ScriptVersionDetect, Interpreter: "/bin/sh", Script: script}, "node-1.4"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sc := ServiceConfig{BinDir: binDir, MainProcess: "node", VersionDetect: tc.detect}
+			require.NoError(t, processVersionDetectConfig(&sc.VersionDetect, &sc, "", new(ServiceDefaultsConfig)))
+
+			version, err := NewVersionDetector("srv", sc, new(ServiceDefaultsConfig)).Detect(context.Background())
+			if tc.version == "" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.version, version)
+		})
+	}
+}
+
+func TestProcessVersionDetectConfig(t *testing.T) {
+	v := VersionDetectConfig{Type: FileVersionDetect}
+	require.NoError(t, processVersionDetectConfig(&v, &ServiceConfig{BinDir: "/usr/local/bin"}, "", new(ServiceDefaultsConfig)))
+	assert.Equal(t, "/usr/local/bin/VERSION", v.File)
+	assert.Equal(t, DefaultVersionTimeout, v.Timeout)
+
+	assert.Error(t, processVersionDetectConfig(&VersionDetectConfig{Type: CommandVersionDetect}, &ServiceConfig{BinDir: "/usr/local/bin"}, "", new(ServiceDefaultsConfig)))
+	assert.Error(t, processVersionDetectConfig(&VersionDetectConfig{Type: FileVersionDetect, Regex: "("}, &ServiceConfig{BinDir: "/usr/local/bin"}, "", new(ServiceDefaultsConfig)))
+	assert.Error(t, processVersionDetectConfig(&VersionDetectConfig{Type: "unknown"}, &ServiceConfig{}, "", new(ServiceDefaultsConfig)))
+}
+
+func TestManager_currentVersion(t *testing.T) {
+	binDir, rmBin := prepareBinDir(t, map[string]string{"VERSION": "v1"})
+	defer rmBin()
+	update, rmUpdate := prepareScript(t, `echo -n "${SWU_TO_VERSION}" > "${SWU_BIN_DIR}/VERSION"`)
+	defer rmUpdate()
+	check, rmCheck := prepareScript(t, "exit 1")
+	defer rmCheck()
+	db, rmDB := prepareDB(t)
+	defer rmDB()
+
+	conf := NewConfig(os.TempDir(), os.TempDir())
+	conf.Services.Services["srv"] = &ServiceConfig{
+		BinDir:        binDir,
+		CheckInterval: -1,
+		VersionDetect: VersionDetectConfig{Type: FileVersionDetect, File: filepath.Join(binDir, "VERSION")},
+		Checker:       CheckerConfig{Type: ScriptCheckerType, Interpreter: "/bin/bash", Script: check},
+		Updater:       UpdaterConfig{Type: ScriptUpdaterType, Interpreter: "/bin/bash", Script: update},
+	}
+	m := NewManager(db, conf)
+	defer func() {
+		require.NoError(t, m.Close())
+	}()
+	ctx := context.Background()
+
+	release, err := m.Check(ctx, "srv")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", release.CurrentVersion)
+	assert.Equal(t, "v1", db.ServiceLastUpdate("srv").Version)
+
+	updated, err := m.Update(ctx, "srv", "v2", UpdateOptions{})
+	require.NoError(t, err)
+	require.True(t, updated)
+	assert.Equal(t, "v2", db.ServiceLastUpdate("srv").Version)
+
+	// The installed version is detected even if it was changed outside of the
+	// updater.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(binDir, "VERSION"), []byte("v3"), 0644))
+	release, err = m.Check(ctx, "srv")
+	require.NoError(t, err)
+	assert.Equal(t, "v3", release.CurrentVersion)
+	last := db.ServiceLastUpdate("srv")
+	assert.Equal(t, "v2", last.Tag)
+	assert.Equal(t, "v3", last.Installed())
+
+	// Updates and rollbacks are recorded (and backed up) as from the detected
+	// version, even without a check.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(binDir, "VERSION"), []byte("v4"), 0644))
+	updated, err = m.Update(ctx, "srv", "v5", UpdateOptions{})
+	require.NoError(t, err)
+	require.True(t, updated)
+	backups, err := m.Backups("srv")
+	require.NoError(t, err)
+	require.NotEmpty(t, backups)
+	assert.Equal(t, "v4", backups[0].Version)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(binDir, "VERSION"), []byte("v6"), 0644))
+	restored, err := m.Rollback(ctx, "srv", "v4", UpdateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "v4", restored)
+	assert.True(t, db.ServiceLastUpdate("srv").IsRolledBack("v6"))
+
+	history, err := m.History("srv")
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, "v4", history[1].FromVersion)
+	assert.Equal(t, "v6", history[2].FromVersion)
+}
@@ -0,0 +1,132 @@
+package update
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WindowError occurs when an update is attempted outside of the service's
+// maintenance windows without being forced.
+type WindowError struct {
+	Next time.Time // When the next maintenance window opens.
+}
+
+// Error implements error.
+func (e *WindowError) Error() string {
+	return fmt.Sprintf("outside of the service's maintenance windows (next window opens at %s), force is required to update now",
+		e.Next.Format(time.RFC3339))
+}
+
+// MaintenanceConfig configures when updates may be applied to a service.
+// Updates may be applied at any time if no windows are defined.
+type MaintenanceConfig struct {
+	Timezone string              `yaml:"timezone,omitempty"` // IANA timezone name ("Local" if unspecified).
+	Windows  []MaintenanceWindow `yaml:"windows,omitempty"`
+}
+
+// MaintenanceWindow is a daily time range in which updates may be applied.
+// The window ends on the following day if End is not after Start.
+type MaintenanceWindow struct {
+	Days  []string `yaml:"days,omitempty"` // Weekdays (e.g. "mon", "Saturday"). Every day if unspecified.
+	Start string   `yaml:"start"`          // Format: "15:04".
+	End   string   `yaml:"end"`            // Format: "15:04".
+}
+
+// Open checks whether t is within one of the maintenance windows. If not, the
+// start of the next window is also returned.
+func (m MaintenanceConfig) Open(t time.Time) (bool, time.Time, error) {
+	if len(m.Windows) == 0 {
+		return true, time.Time{}, nil
+	}
+	loc := time.Local
+	if m.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(m.Timezone); err != nil {
+			return false, time.Time{}, fmt.Errorf("invalid maintenance timezone '%s': %s", m.Timezone, err)
+		}
+	}
+	t = t.In(loc)
+
+	var next time.Time
+	for _, w := range m.Windows {
+		days, start, end, err := w.parse()
+		if err != nil {
+			return false, time.Time{}, err
+		}
+		// Windows that started yesterday may still be open, and the next window
+		// of a given weekday is at most a week away.
+		for i := -1; i <= 7; i++ {
+			from := time.Date(t.Year(), t.Month(), t.Day()+i, 0, start, 0, 0, loc)
+			if !days[from.Weekday()] {
+				continue
+			}
+			to := time.Date(t.Year(), t.Month(), t.Day()+i, 0, end, 0, 0, loc)
+			if end <= start {
+				to = to.AddDate(0, 0, 1)
+			}
+			if !t.Before(from) && t.Before(to) {
+				return true, time.Time{}, nil
+			}
+			if from.After(t) && (next.IsZero() || from.Before(next)) {
+				next = from
+			}
+		}
+	}
+	return false, next, nil
+}
+
+// parse returns the weekdays of the window, and its start and end in minutes
+// since midnight.
+func (w MaintenanceWindow) parse() (days [7]bool, start, end int, err error) {
+	if len(w.Days) == 0 {
+		for i := range days {
+			days[i] = true
+		}
+	}
+	for _, d := range w.Days {
+		wd, err := parseWeekday(d)
+		if err != nil {
+			return days, 0, 0, err
+		}
+		days[wd] = true
+	}
+	if start, err = parseTimeOfDay(w.Start); err != nil {
+		return days, 0, 0, err
+	}
+	if end, err = parseTimeOfDay(w.End); err != nil {
+		return days, 0, 0, err
+	}
+	return days, start, end, nil
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(s)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid maintenance window day '%s'", s)
+}
+
+func parseTimeOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid maintenance window time '%s', expecting format 'HH:MM'", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Checks for errors and fills unspecified fields with default values.
+func processMaintenanceConfig(m *MaintenanceConfig, d *MaintenanceConfig) error {
+	if len(m.Windows) == 0 {
+		m.Windows = d.Windows
+		if m.Timezone == "" {
+			m.Timezone = d.Timezone
+		}
+	}
+	_, _, err := m.Open(time.Now())
+	return err
+}
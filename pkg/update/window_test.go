@@ -0,0 +1,108 @@
+package update
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceConfig_Open(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse(time.RFC3339, s)
+		require.NoError(t, err)
+		return v
+	}
+
+	// 2019-04-20 is a Saturday.
+	m := MaintenanceConfig{
+		Timezone: "UTC",
+		Windows: []MaintenanceWindow{
+			{Days: []string{"sat", "Sunday"}, Start: "02:00", End: "05:00"},
+			{Days: []string{"wed"}, Start: "23:00", End: "01:00"},
+		},
+	}
+	cases := []struct {
+		Now  string
+		Open bool
+		Next string
+	}{
+		{"2019-04-20T03:00:00Z", true, ""},
+		{"2019-04-20T02:00:00Z", true, ""},
+		{"2019-04-20T05:00:00Z", false, "2019-04-21T02:00:00Z"},
+		{"2019-04-21T06:00:00Z", false, "2019-04-24T23:00:00Z"},
+		{"2019-04-25T00:30:00Z", true, ""},
+		{"2019-04-25T01:00:00Z", false, "2019-04-27T02:00:00Z"},
+	}
+	for _, c := range cases {
+		open, next, err := m.Open(at(c.Now))
+		require.NoError(t, err, c.Now)
+		assert.Equal(t, c.Open, open, c.Now)
+		if !c.Open {
+			assert.True(t, at(c.Next).Equal(next), "%s: %s", c.Now, next)
+		}
+	}
+
+	open, _, err := MaintenanceConfig{}.Open(time.Now())
+	require.NoError(t, err)
+	assert.True(t, open)
+
+	_, _, err = MaintenanceConfig{Windows: []MaintenanceWindow{{Start: "2am", End: "05:00"}}}.Open(time.Now())
+	assert.Error(t, err)
+
+	_, _, err = MaintenanceConfig{Windows: []MaintenanceWindow{{Days: []string{"someday"}, Start: "02:00", End: "05:00"}}}.Open(time.Now())
+	assert.Error(t, err)
+
+	_, _, err = MaintenanceConfig{Timezone: "Nowhere/Nothing", Windows: m.Windows}.Open(time.Now())
+	assert.Error(t, err)
+}
+
+func TestManager_maintenanceWindows(t *testing.T) {
+	check, rmCheck := prepareScript(t, "exit 0")
+	defer rmCheck()
+	update, rmUpdate := prepareScript(t, "exit 0")
+	defer rmUpdate()
+	db, rmDB := prepareDB(t)
+	defer rmDB()
+
+	// A window which is never open now.
+	now := time.Now().UTC()
+	closed := MaintenanceConfig{
+		Timezone: "UTC",
+		Windows: []MaintenanceWindow{{
+			Start: now.Add(2 * time.Hour).Format("15:04"),
+			End:   now.Add(3 * time.Hour).Format("15:04"),
+		}},
+	}
+	conf := NewConfig(os.TempDir(), os.TempDir())
+	conf.Services.Services["my-service"] = &ServiceConfig{
+		CheckInterval: -1,
+		Policy:        PolicyConfig{Mode: AutoPolicy},
+		Maintenance:   closed,
+		Checker:       CheckerConfig{Type: ScriptCheckerType, Interpreter: "/bin/bash", Script: check},
+		Updater:       UpdaterConfig{Type: ScriptUpdaterType, Interpreter: "/bin/bash", Script: update},
+	}
+	m := NewManager(db, conf)
+	defer func() {
+		require.NoError(t, m.Close())
+	}()
+
+	_, err := m.Check(context.TODO(), "my-service")
+	require.NoError(t, err)
+	status, err := m.LastCheck("my-service")
+	require.NoError(t, err)
+	assert.Equal(t, DeferAction, status.Decision.Action)
+	require.NotNil(t, status.Decision.DeferredUntil)
+	assert.True(t, status.Decision.DeferredUntil.After(now))
+	assert.True(t, db.ServiceLastUpdate("my-service").IsEmpty())
+
+	_, err = m.Update(context.TODO(), "my-service", "v1.0", UpdateOptions{})
+	require.IsType(t, &WindowError{}, err)
+
+	ok, err := m.Update(context.TODO(), "my-service", "v1.0", UpdateOptions{Force: true})
+	require.NoError(t, err)
+	assert.True(t, ok)
+}